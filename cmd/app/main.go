@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/Quiexx/narrator-bot/internal/app"
 	"github.com/Quiexx/narrator-bot/internal/config"
@@ -15,7 +19,10 @@ func main() {
 		log.Fatalf("failed to retrieve env variables, %v", err)
 	}
 
-	if err := app.Run(cfg); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.Run(ctx, cfg); err != nil {
 		log.Fatalf("failed to start app, %v", err)
 	}
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Quiexx/narrator-bot/internal/app"
+	"github.com/Quiexx/narrator-bot/internal/config"
+	"github.com/caarlos0/env/v10"
+)
+
+func main() {
+	cfg := &config.Config{}
+
+	if err := env.Parse(cfg); err != nil {
+		log.Fatalf("failed to retrieve env variables, %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.RotateKeys(ctx, cfg); err != nil {
+		log.Fatalf("failed to rotate keys, %v", err)
+	}
+}
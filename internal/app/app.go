@@ -1,68 +1,282 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/Quiexx/narrator-bot/internal/audio"
+	"github.com/Quiexx/narrator-bot/internal/audiocache"
+	"github.com/Quiexx/narrator-bot/internal/blocklist"
 	"github.com/Quiexx/narrator-bot/internal/bot"
 	"github.com/Quiexx/narrator-bot/internal/config"
+	"github.com/Quiexx/narrator-bot/internal/health"
+	"github.com/Quiexx/narrator-bot/internal/logging"
+	"github.com/Quiexx/narrator-bot/internal/metrics"
+	"github.com/Quiexx/narrator-bot/internal/ratelimit"
 	"github.com/Quiexx/narrator-bot/internal/repository"
+	"github.com/Quiexx/narrator-bot/internal/secret"
 	"github.com/Quiexx/narrator-bot/internal/steosvoice"
+	"github.com/Quiexx/narrator-bot/internal/voicecache"
+	"github.com/Quiexx/narrator-bot/internal/workerpool"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func Run(cfg *config.Config) error {
+const (
+	HEALTHZ_PATTERN = "/healthz"
+	READYZ_PATTERN  = "/readyz"
+)
 
-	dsn := fmt.Sprintf(
-		"host=%v user=%v password=%v dbname=%v port=%v sslmode=%v",
-		cfg.PostgresHost,
-		cfg.PostgresUser,
-		cfg.PostgresPassword,
-		cfg.PostgresDBName,
-		cfg.PostgresPort,
-		cfg.PostgresSSMode,
-	)
+// Run serves updates until ctx is done, then lets updates in progress finish
+// within SHUTDOWN_TIMEOUT.
+func Run(ctx context.Context, cfg *config.Config) error {
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	logger, err := newLogger(cfg)
 	if err != nil {
 		return err
 	}
 
+	db, err := openDB(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer closeDB(db, logger)
+
 	err = repository.MigrateModels(db)
+	if err != nil {
+		return fmt.Errorf("migrate models: %w", err)
+	}
+
+	keyring, err := newKeyring(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("create keyring: %w", err)
+	}
+
+	tgurep := repository.NewTgUserRepository(db, keyring)
+
+	if keyring == nil {
+		encrypted, err := tgurep.HasEncryptedApiKeys(ctx)
+		if err != nil {
+			return err
+		}
+		if encrypted {
+			return errors.New("api keys are stored encrypted, ENCRYPTION_KEY_ID and ENCRYPTION_KEYS are required")
+		}
+	}
+
+	svApi := steosvoice.NewSteosVoiceAPI(
+		steosvoice.NewHTTPClient(),
+		cfg.SteosVoiceCallTimeout,
+		cfg.SteosVoiceMaxRetries,
+		cfg.SteosVoiceBackoff,
+		logger,
+	)
+
+	audioEncoder, err := audio.NewEncoder(cfg.AudioEncoder, cfg.FFmpegPath, cfg.AudioEncodeTimeout)
 	if err != nil {
 		return err
 	}
 
-	tgurep := repository.NewTgUserRepository(db)
-	client := &http.Client{}
-	svApi := steosvoice.NewSteosVoiceAPI(client)
+	audioFetcher := audio.NewFetcher(&http.Client{Timeout: cfg.AudioFetchTimeout}, cfg.AudioMaxSize)
+
+	pool := workerpool.New(cfg.Workers, cfg.MaxQueuedUpdates, metrics.ObserveQueueWait)
+	voiceCache := voicecache.New(svApi, cfg.VoiceCacheTTL)
+	audioCache := audiocache.New(repository.NewAudioCacheRepository(db))
+
+	blocked := blocklist.New(repository.NewBlocklistRepository(db))
+	err = blocked.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load blocklist: %w", err)
+	}
+	go blocked.Run(ctx, logger)
+
+	metrics.RegisterPool(pool.Stats)
+	metrics.RegisterCache("voices", voiceCache.Stats)
+	metrics.RegisterCache("audio", audioCache.Stats)
 
 	bot, err := bot.NewTgBot(
-		cfg.BotToken,
-		cfg.SetWebhookUrl,
-		cfg.ServerUrl,
-		cfg.WebhookPattern,
-		svApi,
-		tgurep,
+		bot.Options{
+			Token:          cfg.BotToken,
+			ServerUrl:      cfg.ServerUrl,
+			WebhookPattern: cfg.WebhookPattern,
+			WebhookSecret:  cfg.WebhookSecret,
+			ChunkLimit:     cfg.SynthesisChunkLimit,
+			InlineChatId:   cfg.InlineChatId,
+			InlineDebounce: cfg.InlineDebounce,
+			AdminIds:       cfg.AdminIds,
+			StateTimeout:   cfg.StateTimeout,
+			CallbackTTL:    cfg.CallbackTTL,
+		},
+		bot.Deps{
+			Provider:         svApi,
+			TgUserRep:        tgurep,
+			VoiceCache:       voiceCache,
+			HistoryRep:       repository.NewHistoryRepository(db),
+			AudioCache:       audioCache,
+			AudioFetcher:     audioFetcher,
+			AudioEncoder:     audioEncoder,
+			ChatSettingsRep:  repository.NewChatSettingsRepository(db),
+			Pool:             pool,
+			Limiter:          ratelimit.New(cfg.UserRateLimit, cfg.UserRateBurst, cfg.GlobalRateLimit, cfg.GlobalRateBurst),
+			Blocklist:        blocked,
+			FavoriteVoiceRep: repository.NewFavoriteVoiceRepository(db),
+			Logger:           logger,
+		},
 	)
 
 	if err != nil {
-		return err
+		return fmt.Errorf("create bot: %w", err)
+	}
+
+	err = bot.SetCommands()
+	if err != nil {
+		logger.Error("failed to set bot commands", "error", err)
+	}
+
+	http.Handle(cfg.MetricsPattern, promhttp.Handler())
+	http.Handle(HEALTHZ_PATTERN, health.Liveness())
+	http.Handle(READYZ_PATTERN, health.Readiness(cfg.HealthCheckTimeout, map[string]health.Check{
+		"postgres": postgresCheck(db),
+		"telegram": bot.TelegramCheck(),
+		"updates":  bot.UpdatesCheck(cfg.UpdateStallTimeout),
+	}))
+
+	if bot.UsesWebhook() {
+		http.Handle(cfg.WebhookPattern, bot.WebhookHandler())
+
+		err = bot.SetWebhooks()
+		if err != nil {
+			return fmt.Errorf("set webhook: %w", err)
+		}
+
+		defer func() {
+			if err := bot.DeleteWebhooks(); err != nil {
+				logger.Error("failed to delete webhook", "error", err)
+			}
+		}()
 	}
 
-	// err = bot.SetWebhooks()
+	go func() {
+		if err := bot.Start(ctx); err != nil {
+			logger.Error("failed to receive updates", "error", err)
+		}
+	}()
+
+	server := &http.Server{Addr: ":8080"}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serverErr:
+	case <-ctx.Done():
+	}
 
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// stop accepting webhook updates first, telegram redelivers rejected ones
+	// later and the bot handles the accepted ones before shutting down
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to shut down server", "error", err)
+	}
+
+	if err := bot.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to finish updates in progress", "error", err)
+	}
+	logPoolStats(logger, pool.Stats())
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func newLogger(cfg *config.Config) (*slog.Logger, error) {
+	secrets := []string{cfg.BotToken, cfg.WebhookSecret, cfg.PostgresPassword}
+	for _, key := range cfg.EncryptionKeys {
+		secrets = append(secrets, key)
+	}
+
+	logger, err := logging.New(cfg.LogLevel, cfg.LogFormat, os.Stderr, secrets...)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// the standard log package and libraries using it write through logger too
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+func openDB(cfg *config.Config, logger *slog.Logger) (*gorm.DB, error) {
+	dsn := fmt.Sprintf(
+		"host=%v user=%v password=%v dbname=%v port=%v sslmode=%v",
+		cfg.PostgresHost,
+		cfg.PostgresUser,
+		cfg.PostgresPassword,
+		cfg.PostgresDBName,
+		cfg.PostgresPort,
+		cfg.PostgresSSMode,
+	)
+
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: repository.NewGormLogger(logger, cfg.SlowQueryThreshold),
+	})
+}
+
+func logPoolStats(logger *slog.Logger, stats workerpool.Stats) {
+	var avgWait time.Duration
+	if stats.Processed > 0 {
+		avgWait = stats.WaitTotal / time.Duration(stats.Processed)
 	}
 
-	go bot.Start()
+	logger.Info(
+		"update queue stats",
+		"processed", stats.Processed,
+		"rejected", stats.Rejected,
+		"avg_wait", avgWait,
+		"max_wait", stats.WaitMax,
+	)
+}
+
+func postgresCheck(db *gorm.DB) health.Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
 
-	err = http.ListenAndServe(":8080", nil)
+		stats := sqlDB.Stats()
+		details := map[string]interface{}{"open_connections": stats.OpenConnections}
+
+		return details, sqlDB.PingContext(ctx)
+	}
+}
+
+func closeDB(db *gorm.DB, logger *slog.Logger) {
+	sqlDB, err := db.DB()
 	if err != nil {
-		return err
+		logger.Error("failed to get db pool", "error", err)
+		return
+	}
+
+	if err := sqlDB.Close(); err != nil {
+		logger.Error("failed to close db pool", "error", err)
+	}
+}
+
+func newKeyring(cfg *config.Config, logger *slog.Logger) (*secret.Keyring, error) {
+	if cfg.EncryptionKeyId == "" {
+		logger.Warn("ENCRYPTION_KEY_ID is not set, api keys are stored unencrypted")
+		return nil, nil
 	}
 
-	return nil
+	return secret.NewKeyring(cfg.EncryptionKeyId, cfg.EncryptionKeys)
 }
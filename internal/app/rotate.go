@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Quiexx/narrator-bot/internal/config"
+	"github.com/Quiexx/narrator-bot/internal/repository"
+)
+
+// RotateKeys re-encrypts stored api keys with the primary encryption key.
+// Run it after adding a new key to ENCRYPTION_KEYS and pointing
+// ENCRYPTION_KEY_ID to it, then drop the old key from the config.
+func RotateKeys(ctx context.Context, cfg *config.Config) error {
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return err
+	}
+
+	keyring, err := newKeyring(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("create keyring: %w", err)
+	}
+
+	if keyring == nil {
+		return errors.New("ENCRYPTION_KEY_ID is required to rotate keys")
+	}
+
+	db, err := openDB(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer closeDB(db, logger)
+
+	tgurep := repository.NewTgUserRepository(db, keyring)
+
+	updated, err := tgurep.ReencryptApiKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("re-encrypted api keys", "users", updated)
+	return nil
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+const (
+	NONE_ENCODER   = "none"
+	FFMPEG_ENCODER = "ffmpeg"
+
+	OGG_FORMAT = "ogg"
+)
+
+// Encoder converts audio before it is uploaded to telegram. It returns the
+// encoded audio and its format.
+type Encoder interface {
+	Encode(ctx context.Context, data []byte, format string) ([]byte, string, error)
+}
+
+func NewEncoder(name string, ffmpegPath string, timeout time.Duration) (Encoder, error) {
+	switch name {
+	case NONE_ENCODER, "":
+		return NopEncoder{}, nil
+	case FFMPEG_ENCODER:
+		// without ffmpeg every voice message would fail to encode
+		path, err := exec.LookPath(ffmpegPath)
+		if err != nil {
+			return nil, fmt.Errorf("ffmpeg encoder: %w, set AUDIO_ENCODER=%v to upload audio as is", err, NONE_ENCODER)
+		}
+		return &FFmpegEncoder{path: path, timeout: timeout}, nil
+	default:
+		return nil, fmt.Errorf("unknown audio encoder %q", name)
+	}
+}
+
+// NopEncoder uploads audio as the provider returned it.
+type NopEncoder struct{}
+
+func (NopEncoder) Encode(ctx context.Context, data []byte, format string) ([]byte, string, error) {
+	return data, format, nil
+}
+
+// FFmpegEncoder transcodes audio to OGG/Opus, so telegram shows voice
+// messages with waveform and duration.
+type FFmpegEncoder struct {
+	path    string
+	timeout time.Duration
+}
+
+func (e *FFmpegEncoder) Encode(ctx context.Context, data []byte, format string) ([]byte, string, error) {
+	if format == OGG_FORMAT {
+		return data, format, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(
+		ctx,
+		e.path,
+		"-hide_banner", "-loglevel", "error",
+		"-f", format, "-i", "pipe:0",
+		"-vn", "-c:a", "libopus", "-b:a", "48k",
+		"-f", "ogg", "pipe:1",
+	)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to transcode audio: %w: %v", err, stderr.String())
+	}
+
+	return stdout.Bytes(), OGG_FORMAT, nil
+}
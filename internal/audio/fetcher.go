@@ -0,0 +1,54 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var ErrTooLarge = errors.New("audio file is too large")
+
+// Fetcher downloads synthesized audio from the provider.
+type Fetcher struct {
+	client  *http.Client
+	maxSize int64
+}
+
+// NewFetcher limits downloads to maxSize bytes. Timeouts are taken from client.
+func NewFetcher(client *http.Client, maxSize int64) *Fetcher {
+	return &Fetcher{client: client, maxSize: maxSize}
+}
+
+func (f *Fetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch audio: %v", resp.Status)
+	}
+
+	if resp.ContentLength > f.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > f.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	return data, nil
+}
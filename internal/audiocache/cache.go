@@ -0,0 +1,84 @@
+package audiocache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Quiexx/narrator-bot/internal/logging"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/repository"
+	"github.com/Quiexx/narrator-bot/internal/tts"
+)
+
+// Cache maps narrated text to the telegram file id of its voice message, so
+// the same text narrated with the same voice and format is never synthesized
+// twice.
+type Cache struct {
+	rep    *repository.AudioCacheRepository
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func New(rep *repository.AudioCacheRepository) *Cache {
+	return &Cache{rep: rep}
+}
+
+// Key addresses audio by its content. Texts differing only in whitespace
+// share the key.
+func Key(text string, voiceId int64, format string, params tts.SpeechParams) string {
+	normalized := strings.Join(strings.Fields(text), " ")
+	content := fmt.Sprintf("%v|%v|%v|%v|%v|%v|%v", voiceId, format, params.Speed, params.Pitch, params.Volume, params.Emotion, normalized)
+
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) Lookup(ctx context.Context, text string, voiceId int64, format string, params tts.SpeechParams) (string, bool, error) {
+	entry, err := c.rep.Find(ctx, Key(text, voiceId, format, params))
+	if err != nil {
+		return "", false, err
+	}
+
+	if entry == nil || entry.FileId == "" {
+		c.misses.Add(1)
+		return "", false, nil
+	}
+
+	c.hits.Add(1)
+	err = c.rep.IncrementHits(ctx, entry.ID)
+	if err != nil {
+		// the audio is still there, synthesizing it again would spend symbols
+		logging.FromContext(ctx, slog.Default()).Warn("failed to count audio cache hit", "error", err)
+	}
+
+	return entry.FileId, true, nil
+}
+
+func (c *Cache) Store(ctx context.Context, text string, voiceId int64, format string, params tts.SpeechParams, fileId string) error {
+	return c.rep.Save(ctx, &model.AudioCacheEntry{
+		Key:     Key(text, voiceId, format, params),
+		VoiceId: voiceId,
+		Format:  format,
+		FileId:  fileId,
+	})
+}
+
+// Invalidate drops the entry, e.g. when telegram no longer accepts its file id.
+func (c *Cache) Invalidate(ctx context.Context, text string, voiceId int64, format string, params tts.SpeechParams) error {
+	entry, err := c.rep.Find(ctx, Key(text, voiceId, format, params))
+	if err != nil || entry == nil {
+		return err
+	}
+
+	return c.rep.Delete(ctx, entry.ID)
+}
+
+// Stats returns hits and misses since start.
+func (c *Cache) Stats() (int64, int64) {
+	return c.hits.Load(), c.misses.Load()
+}
@@ -0,0 +1,108 @@
+package blocklist
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+)
+
+const (
+	// REFRESH_INTERVAL bounds how long users blocked directly in the database
+	// stay unnoticed.
+	REFRESH_INTERVAL = time.Minute
+	LOAD_TIMEOUT     = 10 * time.Second
+)
+
+type Repository interface {
+	BlockedIds(ctx context.Context) ([]int64, error)
+	Block(ctx context.Context, user *model.BlockedUser) error
+	Unblock(ctx context.Context, tgId int64) error
+}
+
+// Blocklist keeps blocked telegram ids in memory, so checking an update
+// doesn't query the database. Block and Unblock update it at once, Run
+// reloads it in the background.
+type Blocklist struct {
+	rep Repository
+
+	mu  sync.RWMutex
+	ids map[int64]bool
+}
+
+func New(rep Repository) *Blocklist {
+	return &Blocklist{rep: rep, ids: map[int64]bool{}}
+}
+
+func (l *Blocklist) IsBlocked(tgId int64) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.ids[tgId]
+}
+
+// Load replaces the blocked ids with the ones in the database. The list
+// stays as it was if they can't be read within LOAD_TIMEOUT.
+func (l *Blocklist) Load(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, LOAD_TIMEOUT)
+	defer cancel()
+
+	tgIds, err := l.rep.BlockedIds(ctx)
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[int64]bool, len(tgIds))
+	for _, id := range tgIds {
+		ids[id] = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ids = ids
+	return nil
+}
+
+// Run reloads the list every REFRESH_INTERVAL until ctx is done.
+func (l *Blocklist) Run(ctx context.Context, logger *slog.Logger) {
+	ticker := time.NewTicker(REFRESH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.Load(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Error("failed to reload blocklist", "error", err)
+			}
+		}
+	}
+}
+
+func (l *Blocklist) Block(ctx context.Context, user *model.BlockedUser) error {
+	err := l.rep.Block(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ids[user.TgId] = true
+	return nil
+}
+
+func (l *Blocklist) Unblock(ctx context.Context, tgId int64) error {
+	err := l.rep.Unblock(ctx, tgId)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.ids, tgId)
+	return nil
+}
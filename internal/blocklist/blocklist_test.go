@@ -0,0 +1,62 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+)
+
+type fakeRepository struct {
+	ids []int64
+	err error
+}
+
+func (r *fakeRepository) BlockedIds(ctx context.Context) ([]int64, error) {
+	return r.ids, r.err
+}
+
+func (r *fakeRepository) Block(ctx context.Context, user *model.BlockedUser) error {
+	return r.err
+}
+
+func (r *fakeRepository) Unblock(ctx context.Context, tgId int64) error {
+	return r.err
+}
+
+func TestBlocklist(t *testing.T) {
+	rep := &fakeRepository{ids: []int64{1}}
+	l := New(rep)
+	ctx := context.Background()
+
+	if err := l.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !l.IsBlocked(1) || l.IsBlocked(2) {
+		t.Fatal("loaded ids aren't blocked")
+	}
+
+	if err := l.Block(ctx, &model.BlockedUser{TgId: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Unblock(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if l.IsBlocked(1) || !l.IsBlocked(2) {
+		t.Fatal("blocklist isn't updated at once")
+	}
+
+	rep.err = errors.New("db is down")
+
+	if err := l.Load(ctx); err == nil {
+		t.Fatal("failed load returns no error")
+	}
+	if !l.IsBlocked(2) {
+		t.Fatal("stale list isn't kept after a failed load")
+	}
+
+	if err := l.Block(ctx, &model.BlockedUser{TgId: 3}); err == nil || l.IsBlocked(3) {
+		t.Fatal("user is blocked in memory only")
+	}
+}
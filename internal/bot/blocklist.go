@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// throttled reports whether the update exceeds the rate limits and must be
+// dropped.
+func (b *TgBot) throttled(update *tgbotapi.Update) bool {
+	if update.InlineQuery != nil {
+		// inline queries are debounced instead
+		return false
+	}
+
+	if callback.Action(update.CallbackData()) == NARRATION_ABORT_ACTION {
+		// a throttled user must still be able to stop a narration
+		return false
+	}
+
+	chat := update.FromChat()
+
+	verdict := b.limiter.Allow(update.SentFrom().ID)
+	if verdict.Allowed {
+		return false
+	}
+
+	if update.CallbackQuery != nil {
+		go b.answerCallback(update.CallbackQuery.ID, "")
+	}
+
+	if verdict.Notify && chat != nil {
+		key := templates.THROTTLED_MESSAGE
+		if verdict.Global {
+			key = templates.GLOBAL_THROTTLED_MESSAGE
+		}
+		go b.sendMessage(update, templates.Lookup(updateLanguage(update), key))
+	}
+
+	return true
+}
+
+// isBlocked answers callbacks of blocked users, so their buttons stop
+// spinning.
+func (b *TgBot) isBlocked(update *tgbotapi.Update) bool {
+	if !b.blocklist.IsBlocked(update.SentFrom().ID) {
+		return false
+	}
+
+	if update.CallbackQuery != nil {
+		go b.answerCallback(update.CallbackQuery.ID, "")
+	}
+	return true
+}
+
+func (b *TgBot) isAdmin(tgId int64) bool {
+	for _, id := range b.adminIds {
+		if id == tgId {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *TgBot) handleBlock(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	if !b.isAdmin(tgUser.TgId) {
+		b.sendMessage(update, templates.Text(ctx, templates.UNKNOWN_COMMAND_MESSAGE))
+		return
+	}
+
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) == 0 {
+		b.sendMessage(update, templates.Text(ctx, templates.BLOCK_USAGE_MESSAGE))
+		return
+	}
+
+	tgId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.sendMessage(update, templates.Text(ctx, templates.BLOCK_USAGE_MESSAGE))
+		return
+	}
+
+	err = b.blocklist.Block(ctx, &model.BlockedUser{
+		TgId:      tgId,
+		Reason:    strings.Join(args[1:], " "),
+		BlockedBy: tgUser.TgId,
+	})
+	if err != nil {
+		b.log(ctx).Error("failed to block user", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	b.sendMessage(update, fmt.Sprintf(templates.Text(ctx, templates.USER_BLOCKED_MESSAGE), tgId))
+}
+
+func (b *TgBot) handleUnblock(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	if !b.isAdmin(tgUser.TgId) {
+		b.sendMessage(update, templates.Text(ctx, templates.UNKNOWN_COMMAND_MESSAGE))
+		return
+	}
+
+	tgId, err := strconv.ParseInt(strings.TrimSpace(update.Message.CommandArguments()), 10, 64)
+	if err != nil {
+		b.sendMessage(update, templates.Text(ctx, templates.BLOCK_USAGE_MESSAGE))
+		return
+	}
+
+	err = b.blocklist.Unblock(ctx, tgId)
+	if err != nil {
+		b.log(ctx).Error("failed to unblock user", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	b.sendMessage(update, fmt.Sprintf(templates.Text(ctx, templates.USER_UNBLOCKED_MESSAGE), tgId))
+}
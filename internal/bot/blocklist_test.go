@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Quiexx/narrator-bot/internal/blocklist"
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/ratelimit"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type blockedIds []int64
+
+func (ids blockedIds) BlockedIds(ctx context.Context) ([]int64, error) { return ids, nil }
+
+func (ids blockedIds) Block(ctx context.Context, user *model.BlockedUser) error { return nil }
+
+func (ids blockedIds) Unblock(ctx context.Context, tgId int64) error { return nil }
+
+func TestBlockedUserIsNotThrottled(t *testing.T) {
+	b, telegram := newTestBot(t, nil)
+	b.limiter = ratelimit.New(0.001, 1, 0, 0)
+	b.blocklist = blocklist.New(blockedIds{TEST_CHAT_ID})
+	if err := b.blocklist.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	b.dispatch(newTestUpdate("hello"))
+	b.dispatch(newTestUpdate("hello"))
+
+	if sent := telegram.sent(); len(sent) != 0 {
+		t.Fatalf("blocked user is sent %q", sent)
+	}
+	if !b.limiter.Allow(TEST_CHAT_ID).Allowed {
+		t.Fatal("updates of blocked user take rate limit tokens")
+	}
+}
+
+func TestNarrationAbortIsNotThrottled(t *testing.T) {
+	b, _ := newTestBot(t, nil)
+	b.limiter = ratelimit.New(0.001, 1, 0, 0)
+
+	b.limiter.Allow(TEST_CHAT_ID)
+	if !b.throttled(newTestUpdate("hello")) {
+		t.Fatal("update after the burst isn't throttled")
+	}
+
+	data, err := callback.NewCodec("token", 0).Encode(TEST_CHAT_ID, callback.New(NARRATION_ABORT_ACTION, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abort := &tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   "1",
+			From: &tgbotapi.User{ID: TEST_CHAT_ID},
+			Data: data,
+		},
+	}
+	if b.throttled(abort) {
+		t.Fatal("narration abort is throttled")
+	}
+}
@@ -1,137 +1,394 @@
 package bot
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
-	"math"
-	"net/http"
-	"sort"
-	"strconv"
-	"strings"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
-
+	"unicode/utf8"
+
+	"github.com/Quiexx/narrator-bot/internal/audio"
+	"github.com/Quiexx/narrator-bot/internal/audiocache"
+	"github.com/Quiexx/narrator-bot/internal/blocklist"
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/command"
+	"github.com/Quiexx/narrator-bot/internal/fsm"
+	"github.com/Quiexx/narrator-bot/internal/logging"
+	"github.com/Quiexx/narrator-bot/internal/metrics"
 	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/ratelimit"
 	"github.com/Quiexx/narrator-bot/internal/repository"
-	"github.com/Quiexx/narrator-bot/internal/steosvoice"
+	"github.com/Quiexx/narrator-bot/internal/segmenter"
 	"github.com/Quiexx/narrator-bot/internal/templates"
+	"github.com/Quiexx/narrator-bot/internal/tts"
+	"github.com/Quiexx/narrator-bot/internal/voicecache"
+	"github.com/Quiexx/narrator-bot/internal/workerpool"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 const (
-	START_COMMAND       = "/start"
-	HELP_COMMAND        = "/help"
-	API_KEY_COMMAND     = "/apikey"
-	VOICE_COMMAND       = "/voice"
-	GET_SYMBOLS_COMMAND = "/symbols"
+	START_COMMAND       = "start"
+	HELP_COMMAND        = "help"
+	API_KEY_COMMAND     = "apikey"
+	VOICE_COMMAND       = "voice"
+	GET_SYMBOLS_COMMAND = "symbols"
+	HISTORY_COMMAND     = "history"
+	TARIFFS_COMMAND     = "tariffs"
+	FORMAT_COMMAND      = "format"
+	SETTINGS_COMMAND    = "settings"
+	LANGUAGE_COMMAND    = "language"
+	CANCEL_COMMAND      = "cancel"
+	SAY_COMMAND         = "say"
+	CHAT_KEY_COMMAND    = "chatkey"
+	BLOCK_COMMAND       = "block"
+	UNBLOCK_COMMAND     = "unblock"
 
 	DEFAULT_STATE     = "DEFAULT"
 	SET_API_KEY_STATE = "SET_API_KEY"
 
-	VOICE_PAGE_SIZE = 5
-
-	NOT_ENOUGH_SYMBOLS_ERROR = "Not enough symbols"
-	CONNECTION_TIMEOUT_ERROR = "Connection timeout"
+	HISTORY_PAGE_SIZE = 5
 )
 
-var userVoicesCash = map[uint][]*steosvoice.Voice{}
-var voicesMap = map[int64]*steosvoice.Voice{}
-var langs = map[int64]string{
-	1: "RU",
-	2: "EN",
+type TgBot struct {
+	token            string
+	apiEndpoint      string
+	serverUrl        string
+	webhookPattern   string
+	webhookSecret    string
+	webhookUpdates   chan tgbotapi.Update
+	updates          tgbotapi.UpdatesChannel
+	receivers        sync.WaitGroup
+	stopCh           chan struct{}
+	stopOnce         sync.Once
+	handlerCtx       context.Context
+	cancelHandlers   context.CancelFunc
+	chunkLimit       int
+	narrations       *narrations
+	bot              *tgbotapi.BotAPI
+	provider         tts.Provider
+	tgUserRep        *repository.TgUserRepository
+	voiceCache       *voicecache.Cache
+	historyRep       *repository.HistoryRepository
+	audioCache       *audiocache.Cache
+	audioFetcher     *audio.Fetcher
+	audioEncoder     audio.Encoder
+	inlineChatId     int64
+	inlineDebounce   time.Duration
+	inlineQueries    *inlineQueries
+	chatSettingsRep  *repository.ChatSettingsRepository
+	pool             *workerpool.Pool
+	limiter          *ratelimit.Limiter
+	blocklist        *blocklist.Blocklist
+	favoriteVoiceRep *repository.FavoriteVoiceRepository
+	adminIds         []int64
+	states           *fsm.Machine
+	commands         *command.Router
+	callbacks        *callback.Dispatcher
+	callbackCodec    *callback.Codec
+	logger           *slog.Logger
+
+	// unix nanoseconds, read by health checks
+	startedAt       atomic.Int64
+	lastReceivedAt  atomic.Int64
+	lastProcessedAt atomic.Int64
+	receiving       atomic.Bool
 }
 
-type TgBot struct {
-	token          string
-	setWebhookUrl  string
-	serverUrl      string
-	webhookPattern string
-	bot            *tgbotapi.BotAPI
-	svApi          *steosvoice.SteosVoiceAPI
-	tgUserRep      *repository.TgUserRepository
+// Options are the settings of the bot.
+type Options struct {
+	Token          string
+	ServerUrl      string
+	WebhookPattern string
+	WebhookSecret  string
+	ChunkLimit     int
+	InlineChatId   int64
+	InlineDebounce time.Duration
+	AdminIds       []int64
+	StateTimeout   time.Duration
+	CallbackTTL    time.Duration
 }
 
-func NewTgBot(
-	token string,
-	setWebhookUrl string,
-	serverUrl string,
-	webhookPattern string,
-	svApi *steosvoice.SteosVoiceAPI,
-	tgUserRep *repository.TgUserRepository,
-) (*TgBot, error) {
-	bot, err := tgbotapi.NewBotAPI(token)
+// Deps are the services the bot works with.
+type Deps struct {
+	Provider         tts.Provider
+	TgUserRep        *repository.TgUserRepository
+	VoiceCache       *voicecache.Cache
+	HistoryRep       *repository.HistoryRepository
+	AudioCache       *audiocache.Cache
+	AudioFetcher     *audio.Fetcher
+	AudioEncoder     audio.Encoder
+	ChatSettingsRep  *repository.ChatSettingsRepository
+	Pool             *workerpool.Pool
+	Limiter          *ratelimit.Limiter
+	Blocklist        *blocklist.Blocklist
+	FavoriteVoiceRep *repository.FavoriteVoiceRepository
+	Logger           *slog.Logger
+}
+
+func NewTgBot(opts Options, deps Deps) (*TgBot, error) {
+	bot, err := tgbotapi.NewBotAPI(opts.Token)
 	if err != nil {
 		return nil, err
 	}
-	return &TgBot{
-		token:          token,
-		setWebhookUrl:  setWebhookUrl,
-		serverUrl:      serverUrl,
-		webhookPattern: webhookPattern,
-		bot:            bot,
-		svApi:          svApi,
-		tgUserRep:      tgUserRep,
-	}, nil
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
+
+	b := &TgBot{
+		token:            opts.Token,
+		apiEndpoint:      tgbotapi.APIEndpoint,
+		serverUrl:        opts.ServerUrl,
+		webhookPattern:   opts.WebhookPattern,
+		webhookSecret:    opts.WebhookSecret,
+		webhookUpdates:   make(chan tgbotapi.Update, bot.Buffer),
+		stopCh:           make(chan struct{}),
+		handlerCtx:       handlerCtx,
+		cancelHandlers:   cancelHandlers,
+		chunkLimit:       opts.ChunkLimit,
+		narrations:       newNarrations(),
+		bot:              bot,
+		provider:         deps.Provider,
+		tgUserRep:        deps.TgUserRep,
+		voiceCache:       deps.VoiceCache,
+		historyRep:       deps.HistoryRep,
+		audioCache:       deps.AudioCache,
+		audioFetcher:     deps.AudioFetcher,
+		audioEncoder:     deps.AudioEncoder,
+		inlineChatId:     opts.InlineChatId,
+		inlineDebounce:   opts.InlineDebounce,
+		inlineQueries:    newInlineQueries(),
+		chatSettingsRep:  deps.ChatSettingsRep,
+		pool:             deps.Pool,
+		limiter:          deps.Limiter,
+		blocklist:        deps.Blocklist,
+		favoriteVoiceRep: deps.FavoriteVoiceRep,
+		adminIds:         opts.AdminIds,
+		callbackCodec:    callback.NewCodec(opts.Token, opts.CallbackTTL),
+		logger:           deps.Logger,
+	}
+	b.states = b.newStates(opts.StateTimeout)
+	b.commands = b.newCommands()
+	b.callbacks = b.newCallbacks()
+
+	return b, nil
 }
 
-func (b *TgBot) SetWebhooks() error {
+// Start receives updates until ctx is done. Handlers run with their own
+// context, which is cancelled only if Shutdown runs out of time.
+func (b *TgBot) Start(ctx context.Context) error {
+	b.receivers.Add(1)
+	defer b.receivers.Done()
 
-	_, err := http.Post(
-		fmt.Sprintf(b.setWebhookUrl, b.token, b.serverUrl, b.webhookPattern),
-		"plain/text",
-		nil,
-	)
+	var updates tgbotapi.UpdatesChannel
+
+	if b.UsesWebhook() {
+		updates = b.webhookUpdates
+	} else {
+		// telegram refuses getUpdates while a webhook is registered
+		err := b.DeleteWebhooks()
+		if err != nil {
+			return err
+		}
+
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		updates = b.bot.GetUpdatesChan(u)
+	}
+	b.updates = updates
+
+	b.startedAt.Store(time.Now().UnixNano())
+	b.receiving.Store(true)
+	defer b.receiving.Store(false)
 
-	return err
+	for {
+		select {
+		case <-ctx.Done():
+			b.stopReceiving()
+			return nil
+		case <-b.stopCh:
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+
+			b.dispatch(&update)
+		}
+	}
 }
 
-func (b *TgBot) Start() error {
-	// updateCh := b.bot.ListenForWebhook(b.webhookPattern)
+// dispatch queues the update to the worker pool. Updates of one user are
+// handled in order. Inline queries and narration aborts are queued apart
+// from messages, so they are not stuck behind long narrations.
+func (b *TgBot) dispatch(update *tgbotapi.Update) {
+	metrics.Updates.WithLabelValues(updateType(update)).Inc()
+	b.lastReceivedAt.Store(time.Now().UnixNano())
+
+	from := update.SentFrom()
+	if from == nil || b.ignored(update) {
+		return
+	}
+
+	if b.isBlocked(update) {
+		b.updateLogger(update).Info("ignored update of blocked user")
+		return
+	}
+
+	if b.throttled(update) {
+		return
+	}
+
+	key := fmt.Sprint(from.ID)
+	switch {
+	case update.InlineQuery != nil:
+		key = "inline_" + key
+		b.inlineQueries.push(from.ID, update.InlineQuery.ID)
+	case callback.Action(update.CallbackData()) == NARRATION_ABORT_ACTION:
+		key = "narration_" + key
+	}
+
+	err := b.pool.Submit(key, func() {
+		b.handleUpdate(b.handlerCtx, update)
+		b.lastProcessedAt.Store(time.Now().UnixNano())
+	})
+	if err == nil {
+		return
+	}
+
+	b.updateLogger(update).Error("failed to queue update", "error", err)
+	if update.CallbackQuery != nil {
+		// stops the button spinning, the user can press it again
+		go b.answerCallback(update.CallbackQuery.ID, "")
+	}
+	if errors.Is(err, workerpool.ErrQueueFull) && update.FromChat() != nil && update.FromChat().IsPrivate() {
+		go b.sendMessage(update, templates.Lookup(updateLanguage(update), templates.BUSY_MESSAGE))
+	}
+}
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+// Shutdown stops receiving updates and waits for received updates until ctx
+// is done, then cancels them. The webhook server must be shut down before, so
+// no update is received after that.
+func (b *TgBot) Shutdown(ctx context.Context) error {
+	b.stopReceiving()
+
+	done := make(chan struct{})
+	go func() {
+		b.receivers.Wait()
+		b.drain()
+		b.pool.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		b.cancelHandlers()
+		return nil
+	case <-ctx.Done():
+		b.cancelHandlers()
+		return ctx.Err()
+	}
+}
 
-	updates := b.bot.GetUpdatesChan(u)
-	for update := range updates {
-		go b.handleUpdate(&update)
+// drain queues updates received but not queued yet. Telegram doesn't deliver
+// them again, the webhook has answered them and polling has confirmed them.
+func (b *TgBot) drain() {
+	for {
+		select {
+		case update, ok := <-b.updates:
+			if !ok {
+				return
+			}
+			b.dispatch(&update)
+		default:
+			return
+		}
 	}
+}
 
-	return nil
+// stopReceiving makes the webhook handler reject updates, so telegram
+// delivers them again after restart.
+func (b *TgBot) stopReceiving() {
+	b.stopOnce.Do(func() {
+		close(b.stopCh)
+		if !b.UsesWebhook() {
+			b.bot.StopReceivingUpdates()
+		}
+	})
 }
 
-func (b *TgBot) handleUpdate(update *tgbotapi.Update) {
-	tgUser, err := b.getOrCreateUser(update)
+func (b *TgBot) handleUpdate(ctx context.Context, update *tgbotapi.Update) {
+	if update.SentFrom() == nil {
+		return
+	}
+
+	ctx = logging.WithLogger(ctx, b.updateLogger(update))
+	ctx = templates.WithLanguage(ctx, updateLanguage(update))
+
+	tgUser, err := b.getOrCreateUser(ctx, update)
 
 	if err != nil {
-		go b.sendMessage(update, templates.FAIL_MESSAGE)
-		log.Printf("failed to get or create user: %v\n", err)
+		if update.FromChat() != nil {
+			b.sendMessage(update, templates.Text(ctx, templates.FAIL_MESSAGE))
+		}
+		b.log(ctx).Error("failed to get or create user", "error", err)
 		return
 	}
 
-	if tgUser.State == SET_API_KEY_STATE {
-		go b.setUserApiKey(update, tgUser)
+	ctx = logging.WithLogger(ctx, b.log(ctx).With("tg_user_id", tgUser.ID, "voice_id", tgUser.VoiceId))
+	if tgUser.Language != "" {
+		ctx = templates.WithLanguage(ctx, tgUser.Language)
+	}
+
+	if update.InlineQuery != nil {
+		b.handleInlineQuery(ctx, update, tgUser)
+		return
+	}
+
+	if update.FromChat() == nil {
+		return
+	}
+
+	if !update.FromChat().IsPrivate() {
+		b.handleGroupUpdate(ctx, update, tgUser)
+		return
+	}
+
+	if b.messageIsCommand(update) {
+		b.handleCommand(ctx, update, tgUser)
+		return
+	}
+
+	if b.commandToOtherBot(update) {
+		// pasted commands of other bots aren't text to narrate
+		return
+	}
+
+	if update.CallbackQuery != nil {
+		b.handleCallback(ctx, update, tgUser)
+		return
+	}
+
+	if b.handleState(ctx, update, tgUser) {
 		return
 	}
 
 	switch {
-	case b.messageIsCommand(update):
-		go b.handleCommand(update, tgUser)
-	case update.CallbackData() != "":
-		go b.handleCallback(update, tgUser)
 	case update.Message != nil && update.Message.Text != "":
-		go b.synthesize(update, tgUser)
+		b.synthesize(ctx, update, tgUser)
 	case update.Message != nil && update.Message.Caption != "":
 		update.Message.Text = update.Message.Caption
-		go b.synthesize(update, tgUser)
+		b.synthesize(ctx, update, tgUser)
 	default:
-		go b.sendMessage(update, templates.CAN_NOT_HANDLE)
+		b.sendMessage(update, templates.Text(ctx, templates.CAN_NOT_HANDLE))
 	}
 
 }
 
-func (b *TgBot) getOrCreateUser(update *tgbotapi.Update) (*model.TgUser, error) {
-	tgUser, err := b.tgUserRep.GetOrCreate(update.FromChat().ID, DEFAULT_STATE)
+func (b *TgBot) getOrCreateUser(ctx context.Context, update *tgbotapi.Update) (*model.TgUser, error) {
+	// users are identified by user id, which is also the id of their private chat with the bot
+	tgUser, err := b.tgUserRep.GetOrCreate(ctx, update.SentFrom().ID, DEFAULT_STATE)
 	if err != nil {
 		return nil, err
 	}
@@ -139,68 +396,108 @@ func (b *TgBot) getOrCreateUser(update *tgbotapi.Update) (*model.TgUser, error)
 	return tgUser, nil
 }
 
+// messageIsCommand reports whether the message starts with a command to this bot.
 func (b *TgBot) messageIsCommand(update *tgbotapi.Update) bool {
-	if update.Message != nil {
-		if strings.HasPrefix(update.Message.Text, "/") && update.Message.Entities != nil {
-			for _, ent := range update.Message.Entities {
-				if ent.Type == "bot_command" {
-					return true
-				}
-			}
-		}
-	}
-	return false
+	_, _, err := b.commands.Parse(update.Message)
+	return err == nil
+}
+
+func (b *TgBot) commandToOtherBot(update *tgbotapi.Update) bool {
+	_, _, err := b.commands.Parse(update.Message)
+	return errors.Is(err, command.ErrOtherBot)
 }
 
-func (b *TgBot) synthesize(update *tgbotapi.Update, tgUser *model.TgUser) {
+func (b *TgBot) synthesize(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
 
 	if tgUser.SteosvoiceApiKey == "" {
-		go b.sendMessage(update, templates.NO_API_KEY_MESSAGE)
+		b.sendMessage(update, templates.Text(ctx, templates.NO_API_KEY_MESSAGE))
 		return
 	}
 
 	if tgUser.VoiceId == -1 {
-		go b.sendMessage(update, templates.NO_VOICE_MESSAGE)
+		b.sendMessage(update, templates.Text(ctx, templates.NO_VOICE_MESSAGE))
 		return
 	}
 
-	result, err := b.svApi.GetSynthesizedSpeech(tgUser.SteosvoiceApiKey, update.Message.Text, tgUser.VoiceId)
+	chunks := segmenter.Split(update.Message.Text, b.chunkLimit)
+
+	switch len(chunks) {
+	case 0:
+		b.sendMessage(update, templates.Text(ctx, templates.CAN_NOT_HANDLE))
+	case 1:
+		b.narrateText(ctx, update, tgUser, chunks[0], "")
+	default:
+		b.narrate(ctx, update, tgUser, chunks)
+	}
+}
+
+// synthesizeText returns the audio url for text or notifies the user why it
+// could not be synthesized.
+func (b *TgBot) synthesizeText(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, text string, format string) (string, bool) {
+	speech, err := b.synthesizeSpeech(ctx, tgUser, text, providerFormat(format))
 
 	switch {
-	case err != nil:
-		log.Printf("failed to synthesize text: %v\n", err)
-		go b.sendMessage(update, templates.SOMETHING_GONE_WRONG_MESSAGE)
-	case !result.Status && result.Message == NOT_ENOUGH_SYMBOLS_ERROR:
-		go b.sendMessage(update, templates.NOT_ENOUGH_SYMBOLS)
-	case !result.Status && result.Message == CONNECTION_TIMEOUT_ERROR:
-		go b.sendMessage(update, templates.SERVICE_NOT_AVAILABLE)
-	case !result.Status:
-		log.Printf("failed to synthesize text: %v\n", result.Message)
-		go b.sendMessage(update, templates.SOMETHING_GONE_WRONG_MESSAGE)
+	case err == nil:
+		return speech.AudioUrl, true
+	case errors.Is(err, tts.ErrNotEnoughSymbols):
+		b.sendMessage(update, templates.Text(ctx, templates.NOT_ENOUGH_SYMBOLS))
+	case errors.Is(err, tts.ErrUnavailable), errors.Is(err, tts.ErrTransport):
+		b.sendMessage(update, templates.Text(ctx, templates.SERVICE_NOT_AVAILABLE))
+	case errors.Is(err, tts.ErrUnauthorized):
+		b.sendMessage(update, templates.Text(ctx, templates.INVALID_API_KEY_MESSAGE))
 	default:
-		go b.sendVoice(update, result.AudioUrl)
+		b.log(ctx).Error("failed to synthesize text", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+	}
+
+	return "", false
+}
+
+// synthesizeSpeech narrates text with the user's voice and speech params.
+func (b *TgBot) synthesizeSpeech(ctx context.Context, tgUser *model.TgUser, text string, format string) (*tts.Speech, error) {
+	speech, err := b.provider.Synthesize(ctx, tgUser.SteosvoiceApiKey, text, tgUser.VoiceId, format, userSpeechParams(tgUser))
+
+	metrics.Syntheses.WithLabelValues(b.provider.Name(), metrics.Result(err)).Inc()
+	if err == nil {
+		metrics.SynthesizedCharacters.WithLabelValues(b.provider.Name()).Add(float64(utf8.RuneCountInString(text)))
 	}
 
+	return speech, err
+}
+
+func (b *TgBot) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	start := time.Now()
+	sent, err := b.bot.Send(c)
+	metrics.Since(metrics.TelegramRequestDuration.WithLabelValues(requestKind(c), telegramResult(err)), start)
+	return sent, err
+}
+
+func (b *TgBot) request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	start := time.Now()
+	resp, err := b.bot.Request(c)
+	metrics.Since(metrics.TelegramRequestDuration.WithLabelValues(requestKind(c), telegramResult(err)), start)
+	return resp, err
 }
 
 func (b *TgBot) sendMessage(update *tgbotapi.Update, text string) {
 	msg := tgbotapi.NewMessage(update.FromChat().ID, text)
 	msg.ParseMode = "Markdown"
-	_, err := b.bot.Send(msg)
+	_, err := b.send(msg)
 	if err != nil {
-		log.Printf("failed to send message: %v\n", err)
+		b.updateLogger(update).Error("failed to send message", "error", err)
 	}
 }
 
-func (b *TgBot) sendMessageWithKeyboard(update *tgbotapi.Update, text string, keyboardMarkup tgbotapi.InlineKeyboardMarkup) {
+func (b *TgBot) sendMessageWithKeyboard(update *tgbotapi.Update, text string, keyboardMarkup tgbotapi.InlineKeyboardMarkup) (tgbotapi.Message, error) {
 	msg := tgbotapi.NewMessage(update.FromChat().ID, text)
 	msg.ReplyMarkup = keyboardMarkup
 	msg.ParseMode = "Markdown"
 
-	_, err := b.bot.Send(msg)
+	sent, err := b.send(msg)
 	if err != nil {
-		log.Printf("failed to send message: %v\n", err)
+		b.updateLogger(update).Error("failed to send message", "error", err)
 	}
+	return sent, err
 }
 
 func (b *TgBot) editMessageWithKeyboard(update *tgbotapi.Update, keyboardMarkup tgbotapi.InlineKeyboardMarkup, messageId int) {
@@ -210,9 +507,9 @@ func (b *TgBot) editMessageWithKeyboard(update *tgbotapi.Update, keyboardMarkup
 		keyboardMarkup,
 	)
 
-	_, err := b.bot.Send(msg)
+	_, err := b.send(msg)
 	if err != nil {
-		log.Printf("failed to send message: %v\n", err)
+		b.updateLogger(update).Error("failed to send message", "error", err)
 	}
 }
 
@@ -224,360 +521,98 @@ func (b *TgBot) editMessage(update *tgbotapi.Update, text string, messageId int)
 	)
 	msg.ParseMode = "Markdown"
 
-	_, err := b.bot.Send(msg)
+	_, err := b.send(msg)
 	if err != nil {
-		log.Printf("failed to send message: %v\n", err)
+		b.updateLogger(update).Error("failed to send message", "error", err)
 	}
 }
 
-func (b *TgBot) sendVoice(update *tgbotapi.Update, url string) {
-	fu := tgbotapi.FileURL(url)
-	msg := tgbotapi.NewVoice(update.FromChat().ID, fu)
-	msg.ReplyToMessageID = update.Message.MessageID
-	_, err := b.bot.Send(msg)
+func (b *TgBot) sendAudio(update *tgbotapi.Update, format string, file tgbotapi.RequestFileData, caption string) (tgbotapi.Message, error) {
+	sent, err := b.send(newAudioMessage(update.FromChat().ID, update.Message.MessageID, format, file, caption))
 	if err != nil {
-		log.Printf("failed to send message: %v\n", err)
-	}
-}
-
-func (b *TgBot) handleCommand(update *tgbotapi.Update, tgUser *model.TgUser) {
-
-	switch {
-	case strings.HasPrefix(update.Message.Text, START_COMMAND):
-		go b.handleStart(update, tgUser)
-	case strings.HasPrefix(update.Message.Text, API_KEY_COMMAND):
-		go b.handleApiKey(update, tgUser)
-	case strings.HasPrefix(update.Message.Text, VOICE_COMMAND):
-		go b.handleVoice(update, tgUser)
-	case strings.HasPrefix(update.Message.Text, GET_SYMBOLS_COMMAND):
-		go b.handleGetSymbols(update, tgUser)
-	default:
-		go b.sendMessage(update, templates.UNKNOWN_COMMAND_MESSAGE)
+		b.updateLogger(update).Error("failed to send message", "error", err)
 	}
-
+	return sent, err
 }
 
-func (b *TgBot) handleGetSymbols(update *tgbotapi.Update, tgUser *model.TgUser) {
+func (b *TgBot) handleGetSymbols(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
 	if tgUser.SteosvoiceApiKey == "" {
-		b.sendMessage(update, templates.NO_API_KEY_MESSAGE)
+		b.sendMessage(update, templates.Text(ctx, templates.NO_API_KEY_MESSAGE))
 		return
 	}
 
-	result, err := b.svApi.GetSymbols(tgUser.SteosvoiceApiKey)
-	if err != nil || !result.Status {
-		log.Printf("failed to get symbols count: %v\n", err)
-		b.sendMessage(update, templates.FAIL_COMMAND_MESSAGE)
+	symbols, err := b.provider.Balance(ctx, tgUser.SteosvoiceApiKey)
+	if err != nil {
+		b.log(ctx).Error("failed to get symbols count", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.FAIL_COMMAND_MESSAGE))
 	} else {
-		b.sendMessage(update, fmt.Sprintf(templates.SYMBOL_COUNT_MESSAGE, result.Symbols))
+		b.sendMessage(update, fmt.Sprintf(templates.Text(ctx, templates.SYMBOL_COUNT_MESSAGE), symbols))
 	}
 }
 
-func (b *TgBot) handleStart(update *tgbotapi.Update, tgUser *model.TgUser) {
+func (b *TgBot) handleStart(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	if update.Message.CommandArguments() == VOICE_START_PARAMETER {
+		b.openVoices(ctx, update, tgUser, &voiceFilter{})
+		return
+	}
+
 	ticker := time.NewTicker(time.Second)
-	for _, text := range templates.StartMessages {
-		<-ticker.C
+	defer ticker.Stop()
+
+	for _, text := range templates.StartMessages(ctx) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 		b.sendMessage(update, text)
 	}
 }
 
-func (b *TgBot) handleApiKey(update *tgbotapi.Update, tgUser *model.TgUser) {
-	tgUser.State = SET_API_KEY_STATE
-	err := b.tgUserRep.UpdateUser(tgUser)
+func (b *TgBot) handleApiKey(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	err := b.enterState(ctx, tgUser, SET_API_KEY_STATE, nil)
 	if err != nil {
-		go b.sendMessage(update, templates.SOMETHING_GONE_WRONG_MESSAGE)
+		b.log(ctx).Error("failed to update user state", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
 		return
 	}
-	b.sendMessage(update, templates.SET_API_KEY_MESSAGE)
+	b.sendMessage(update, templates.Text(ctx, templates.SET_API_KEY_MESSAGE))
 }
 
-func (b *TgBot) setUserApiKey(update *tgbotapi.Update, tgUser *model.TgUser) {
+func (b *TgBot) setUserApiKey(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
 	if update.Message == nil {
-		go b.sendMessage(update, templates.NOT_API_KEY_MESSAGE)
+		b.sendMessage(update, templates.Text(ctx, templates.NOT_API_KEY_MESSAGE))
 		return
 	}
 
 	if update.Message.Text == "" {
-		go b.sendMessage(update, templates.NOT_API_KEY_MESSAGE)
+		b.sendMessage(update, templates.Text(ctx, templates.NOT_API_KEY_MESSAGE))
 		return
 	}
 
 	apiKey := update.Message.Text
-	result, err := b.svApi.GetVoices(apiKey)
-	if err != nil || !result.Status {
-		go b.sendMessage(update, templates.FAILD_TO_CONNECT_API_KEY_MESSAGE)
-		return
-	}
-
-	tgUser.SteosvoiceApiKey = apiKey
-
-	if len(result.Voices) != 0 {
-		tgUser.VoiceId = result.Voices[0].Id
-	}
-
-	tgUser.State = DEFAULT_STATE
-	err = b.tgUserRep.UpdateUser(tgUser)
-	if err != nil {
-		go b.sendMessage(update, templates.SOMETHING_GONE_WRONG_MESSAGE)
-		return
-	}
-
-	go b.sendMessage(update, templates.API_KEY_IS_SET_MESSAGE)
-	go b.updateUserVoices(tgUser)
-}
-
-func (b *TgBot) handleVoice(update *tgbotapi.Update, tgUser *model.TgUser) {
-	if tgUser.SteosvoiceApiKey == "" {
-		go b.sendMessage(update, templates.NO_API_KEY_MESSAGE)
-		return
-	}
-
-	go b.updateUserVoices(tgUser)
-	go b.sendVoicesMarkup(update, tgUser, 1, VOICE_PAGE_SIZE, false)
-}
-
-func (b *TgBot) handleCallback(update *tgbotapi.Update, tgUser *model.TgUser) {
-
-	data := update.CallbackData()
-	switch {
-	case strings.Split(data, "_")[0] == "voice":
-		go b.handleVoiceCallback(update, tgUser)
-	}
-}
-
-func (b *TgBot) handleVoiceCallback(update *tgbotapi.Update, tgUser *model.TgUser) {
-
-	data := update.CallbackData()
-	tokens := strings.Split(data, "_")
-
-	if len(tokens) > 2 && tokens[1] == "page" {
-		page, err := strconv.Atoi(tokens[2])
-		if err == nil {
-			go b.sendVoicesMarkup(update, tgUser, page, VOICE_PAGE_SIZE, true)
-		}
-		return
-	}
-
-	if len(tokens) > 3 && tokens[1] == "info" {
-		voiceId, err := strconv.Atoi(tokens[2])
-		if err != nil {
-			return
-		}
-		page, err := strconv.Atoi(tokens[3])
-
-		if err == nil {
-			go b.sendVoiceDescription(update, tgUser, int64(voiceId), page)
-		}
-		return
-	}
-
-	voiceId, err := strconv.Atoi(tokens[1])
+	voices, err := b.provider.Voices(ctx, apiKey)
 	if err != nil {
-		go b.sendMessage(update, templates.SOMETHING_GONE_WRONG_MESSAGE)
+		b.log(ctx).Warn("failed to connect api key", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.FAILD_TO_CONNECT_API_KEY_MESSAGE))
 		return
 	}
 
-	tgUser.VoiceId = int64(voiceId)
+	tgUser.SteosvoiceApiKey = apiKey
 
-	err = b.tgUserRep.UpdateUser(tgUser)
-	if err != nil {
-		go b.sendMessage(update, templates.SOMETHING_GONE_WRONG_MESSAGE)
-		return
+	if len(voices) != 0 {
+		tgUser.VoiceId = voices[0].Id
 	}
 
-	go b.sendMessage(update, templates.VOICE_IS_SET_MESSAGE)
-
-}
-
-func (b *TgBot) sendVoicesMarkup(update *tgbotapi.Update, tgUser *model.TgUser, startPage int, pageSize int, edit bool) {
-
-	voices, err := b.getUserVoices(tgUser)
-
+	b.states.Reset(tgUser)
+	err = b.tgUserRep.UpdateUser(ctx, tgUser)
 	if err != nil {
-		go b.sendMessage(update, templates.SOMETHING_GONE_WRONG_MESSAGE)
+		b.log(ctx).Error("failed to save api key", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
 		return
 	}
 
-	keyboard := [][]tgbotapi.InlineKeyboardButton{}
-	start := (startPage - 1) * pageSize
-
-	for i, voice := range voices {
-		if i == start+pageSize {
-			break
-		}
-
-		if i >= start {
-			name, ok := voice.Name["RU"]
-			if !ok {
-				name, ok = voice.Name["EN"]
-			}
-			if !ok {
-				name = fmt.Sprint(voice.Id)
-			}
-
-			callbackData := fmt.Sprintf("voice_info_%v_%v", voice.Id, startPage)
-
-			keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
-				{
-					Text:         name,
-					CallbackData: &callbackData,
-				},
-			})
-		}
-	}
-
-	nextPageCallback := fmt.Sprintf("voice_page_%v", startPage+1)
-	prevPageCallback := fmt.Sprintf("voice_page_%v", startPage-1)
-
-	navigation := []tgbotapi.InlineKeyboardButton{}
-
-	maxPage := int(math.Ceil(float64(len(voices)) / float64(pageSize)))
-
-	if startPage != 1 {
-		navigation = append(navigation, tgbotapi.InlineKeyboardButton{
-			Text:         "<",
-			CallbackData: &prevPageCallback,
-		})
-	}
-
-	if startPage != maxPage {
-		navigation = append(navigation, tgbotapi.InlineKeyboardButton{
-			Text:         ">",
-			CallbackData: &nextPageCallback,
-		})
-	}
-
-	keyboard = append(keyboard, navigation)
-
-	keyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(keyboard...)
-
-	text := fmt.Sprintf(
-		"%v\n\nСтраница %v/%v",
-		templates.VOICE_LIST_MESSAGE,
-		startPage,
-		maxPage,
-	)
-
-	if !edit {
-		b.sendMessageWithKeyboard(update, text, keyboardMarkup)
-		return
-	}
-	b.editMessage(update, text, update.CallbackQuery.Message.MessageID)
-	b.editMessageWithKeyboard(update, keyboardMarkup, update.CallbackQuery.Message.MessageID)
-}
-
-func (b *TgBot) sendVoiceDescription(update *tgbotapi.Update, tgUser *model.TgUser, voiceId int64, page int) {
-
-	voice, ok := voicesMap[voiceId]
-
-	if !ok {
-		go b.sendMessage(update, templates.SOMETHING_GONE_WRONG_MESSAGE)
-		return
-	}
-
-	name, ok := voice.Name["RU"]
-	if !ok {
-		name, ok = voice.Name["EN"]
-	}
-	if !ok {
-		name = fmt.Sprint(voice.Id)
-	}
-
-	description, ok := voice.Description["RU"]
-	if !ok {
-		description, ok = voice.Description["EN"]
-	}
-	if !ok {
-		description = ""
-	}
-
-	sex := voice.Sex
-	if sex == "" {
-		sex = "Неизвестен"
-	}
-
-	lang, ok := langs[voice.LangId]
-	if !ok {
-		lang = "Неизвестен"
-	}
-
-	text := fmt.Sprintf(
-		"**%v**\n%v\n\nПол: %v\nРодной язык: %v",
-		name,
-		description,
-		sex,
-		lang,
-	)
-
-	backCallback := fmt.Sprintf("voice_page_%v", page)
-	chooseCallback := fmt.Sprintf("voice_%v", voiceId)
-
-	keyboard := [][]tgbotapi.InlineKeyboardButton{
-		{
-			tgbotapi.InlineKeyboardButton{
-				Text:         "Выбрать",
-				CallbackData: &chooseCallback,
-			},
-		},
-		{
-			tgbotapi.InlineKeyboardButton{
-				Text:         "Назад",
-				CallbackData: &backCallback,
-			},
-		},
-	}
-
-	keyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(keyboard...)
-
-	b.editMessage(update, text, update.CallbackQuery.Message.MessageID)
-	b.editMessageWithKeyboard(update, keyboardMarkup, update.CallbackQuery.Message.MessageID)
-}
-
-func (b *TgBot) getUserVoices(tgUser *model.TgUser) ([]*steosvoice.Voice, error) {
-	voices, ok := userVoicesCash[tgUser.ID]
-	if ok {
-		return voices, nil
-	}
-
-	result, err := b.svApi.GetVoices(tgUser.SteosvoiceApiKey)
-	if err != nil {
-		return nil, err
-	}
-
-	if !result.Status {
-		return nil, errors.New("failed to get user voices")
-	}
-
-	voices = result.Voices
-	sort.Slice(voices, func(i, j int) bool { return voices[i].Id < voices[j].Id })
-	userVoicesCash[tgUser.ID] = voices
-
-	go b.updateVoicesMap(voices)
-
-	return voices, nil
-}
-
-func (b *TgBot) updateUserVoices(tgUser *model.TgUser) ([]*steosvoice.Voice, error) {
-
-	result, err := b.svApi.GetVoices(tgUser.SteosvoiceApiKey)
-	if err != nil {
-		return nil, err
-	}
-
-	if !result.Status {
-		return nil, errors.New("failed to get user voices")
-	}
-
-	voices := result.Voices
-	sort.Slice(voices, func(i, j int) bool { return voices[i].Id < voices[j].Id })
-	userVoicesCash[tgUser.ID] = voices
-
-	go b.updateVoicesMap(voices)
-
-	return voices, nil
-}
-
-func (b *TgBot) updateVoicesMap(voices []*steosvoice.Voice) {
-	for _, voice := range voices {
-		voicesMap[voice.Id] = voice
-	}
+	b.sendMessage(update, templates.Text(ctx, templates.API_KEY_IS_SET_MESSAGE))
+	b.voiceCache.Forget(tgUser.ID)
+	b.updateUserVoices(ctx, tgUser)
 }
@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+	"github.com/Quiexx/narrator-bot/internal/tts"
+	"github.com/Quiexx/narrator-bot/internal/tts/ttstest"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const TEST_CHAT_ID = 7
+
+// fakeTelegram is a bot api server recording the texts of sent messages.
+type fakeTelegram struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/getMe"):
+		io.WriteString(w, `{"ok": true, "result": {"id": 1, "is_bot": true, "username": "test_bot"}}`)
+	case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+		f.mu.Lock()
+		f.texts = append(f.texts, r.FormValue("text"))
+		f.mu.Unlock()
+		io.WriteString(w, `{"ok": true, "result": {"message_id": 2, "chat": {"id": 7, "type": "private"}}}`)
+	default:
+		io.WriteString(w, `{"ok": true, "result": true}`)
+	}
+}
+
+func (f *fakeTelegram) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.texts...)
+}
+
+func newTestBot(t *testing.T, provider tts.Provider) (*TgBot, *fakeTelegram) {
+	telegram := &fakeTelegram{}
+	server := httptest.NewServer(telegram)
+	t.Cleanup(server.Close)
+
+	endpoint := server.URL + "/bot%s/%s"
+	api, err := tgbotapi.NewBotAPIWithClient("token", endpoint, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &TgBot{
+		apiEndpoint: endpoint,
+		bot:         api,
+		provider:    provider,
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}, telegram
+}
+
+func newTestUpdate(text string) *tgbotapi.Update {
+	return &tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			MessageID: 1,
+			From:      &tgbotapi.User{ID: TEST_CHAT_ID},
+			Chat:      &tgbotapi.Chat{ID: TEST_CHAT_ID, Type: "private"},
+			Text:      text,
+		},
+	}
+}
+
+func TestHandleGetSymbols(t *testing.T) {
+	ctx := templates.WithLanguage(context.Background(), templates.EN)
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		balance func(ctx context.Context, apiKey string) (int64, error)
+		want    string
+		calls   int
+	}{
+		{
+			name:    "balance",
+			apiKey:  "key",
+			balance: func(ctx context.Context, apiKey string) (int64, error) { return 1234, nil },
+			want:    "1234",
+			calls:   1,
+		},
+		{
+			name:   "no api key",
+			apiKey: "",
+			want:   templates.Lookup(templates.EN, templates.NO_API_KEY_MESSAGE),
+		},
+		{
+			name:    "provider failure",
+			apiKey:  "key",
+			balance: func(ctx context.Context, apiKey string) (int64, error) { return 0, errors.New("down") },
+			want:    templates.Lookup(templates.EN, templates.FAIL_COMMAND_MESSAGE),
+			calls:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := &ttstest.Provider{BalanceFunc: test.balance}
+			b, telegram := newTestBot(t, provider)
+
+			b.handleGetSymbols(ctx, newTestUpdate("/symbols"), &model.TgUser{SteosvoiceApiKey: test.apiKey})
+
+			sent := telegram.sent()
+			if len(sent) != 1 || !strings.Contains(sent[0], test.want) {
+				t.Fatalf("sent %q, want a message with %q", sent, test.want)
+			}
+
+			calls := provider.Calls("Balance")
+			if len(calls) != test.calls {
+				t.Fatalf("got %v balance calls, want %v", len(calls), test.calls)
+			}
+			for _, apiKey := range calls {
+				if apiKey != test.apiKey {
+					t.Fatalf("balance is called with %q", apiKey)
+				}
+			}
+		})
+	}
+}
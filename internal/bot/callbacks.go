@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	VOICE_PAGE_ACTION      = "voice.page"
+	VOICE_INFO_ACTION      = "voice.info"
+	VOICE_SET_ACTION       = "voice.set"
+	VOICE_FAVORITE_ACTION  = "voice.fav"
+	NARRATION_ABORT_ACTION = "narration.abort"
+	HISTORY_PAGE_ACTION    = "history.page"
+	HISTORY_SEND_ACTION    = "history.send"
+	FORMAT_SET_ACTION      = "format.set"
+	SETTINGS_CHANGE_ACTION = "settings.change"
+	SETTINGS_RESET_ACTION  = "settings.reset"
+	LANGUAGE_SET_ACTION    = "language.set"
+)
+
+func (b *TgBot) newCallbacks() *callback.Dispatcher {
+	d := callback.NewDispatcher()
+
+	d.Register(VOICE_PAGE_ACTION, b.handleVoicePageCallback, callback.INT, callback.STRING)
+	d.Register(VOICE_INFO_ACTION, b.handleVoiceInfoCallback, callback.INT, callback.INT, callback.STRING)
+	d.Register(VOICE_SET_ACTION, b.handleVoiceSetCallback, callback.INT)
+	d.Register(VOICE_FAVORITE_ACTION, b.handleVoiceFavoriteCallback, callback.INT, callback.INT, callback.STRING)
+	d.Register(NARRATION_ABORT_ACTION, b.handleNarrationCallback, callback.INT)
+	d.Register(HISTORY_PAGE_ACTION, b.handleHistoryPageCallback, callback.INT)
+	d.Register(HISTORY_SEND_ACTION, b.handleHistorySendCallback, callback.INT)
+	d.Register(FORMAT_SET_ACTION, b.handleFormatCallback, callback.STRING)
+	d.Register(SETTINGS_CHANGE_ACTION, b.handleSettingsCallback, callback.STRING, callback.STRING)
+	d.Register(SETTINGS_RESET_ACTION, b.handleSettingsResetCallback)
+	d.Register(LANGUAGE_SET_ACTION, b.handleLanguageCallback, callback.STRING)
+
+	return d
+}
+
+// handleCallback answers every press, so telegram stops showing the spinner,
+// and tells the user if the button can't be pressed anymore.
+func (b *TgBot) handleCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	query := update.CallbackQuery
+
+	p, err := b.callbackCodec.Decode(query.From.ID, query.Data)
+	var handler callback.Handler
+	if err == nil {
+		handler, err = b.callbacks.Handler(p)
+	}
+
+	if err != nil {
+		b.log(ctx).Warn("rejected callback", "error", err)
+		b.answerCallback(query.ID, templates.Text(ctx, templates.STALE_BUTTON_MESSAGE))
+		return
+	}
+
+	b.answerCallback(query.ID, "")
+	handler(ctx, update, tgUser, p)
+}
+
+// callbackData encodes p for a button only the sender of update can press.
+func (b *TgBot) callbackData(update *tgbotapi.Update, p *callback.Payload) (*string, error) {
+	data, err := b.callbackCodec.Encode(update.SentFrom().ID, p)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// callbackButton leaves the button without data if p can't be encoded,
+// callbackKeyboard skips it then.
+func (b *TgBot) callbackButton(update *tgbotapi.Update, text string, p *callback.Payload) tgbotapi.InlineKeyboardButton {
+	data, err := b.callbackData(update, p)
+	if err != nil {
+		b.updateLogger(update).Error("failed to encode callback", "error", err)
+	}
+	return tgbotapi.InlineKeyboardButton{Text: text, CallbackData: data}
+}
+
+// callbackKeyboard skips buttons without data, telegram rejects the whole
+// keyboard for one invalid button.
+func callbackKeyboard(rows ...[]tgbotapi.InlineKeyboardButton) tgbotapi.InlineKeyboardMarkup {
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+	for _, row := range rows {
+		buttons := []tgbotapi.InlineKeyboardButton{}
+		for _, button := range row {
+			if button.CallbackData != nil {
+				buttons = append(buttons, button)
+			}
+		}
+		if len(buttons) != 0 {
+			keyboard = append(keyboard, buttons)
+		}
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
+
+func (b *TgBot) answerCallback(queryId string, text string) {
+	_, err := b.request(tgbotapi.NewCallback(queryId, text))
+	if err != nil {
+		b.logger.Error("failed to answer callback query", "error", err)
+	}
+}
@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+)
+
+// TestCallbackPayloadsFit encodes the longest payload of every action, a
+// button whose payload doesn't fit is dropped from its keyboard.
+func TestCallbackPayloadsFit(t *testing.T) {
+	sexes := strings.Split("a,b,c,d,e,f,g,h,i", ",")
+	filter := (&voiceFilter{
+		Sex:       sexes[len(sexes)-1],
+		LangId:    99,
+		Favorites: true,
+		Query:     strings.Repeat("я", VOICE_QUERY_LENGTH/2),
+	}).encode(sexes)
+
+	payloads := []*callback.Payload{
+		callback.New(VOICE_PAGE_ACTION, 999, filter),
+		callback.New(VOICE_INFO_ACTION, 999999, 999, filter),
+		callback.New(VOICE_SET_ACTION, 999999),
+		callback.New(VOICE_FAVORITE_ACTION, 999999, 999, filter),
+		callback.New(NARRATION_ABORT_ACTION, 9999999999),
+		callback.New(HISTORY_PAGE_ACTION, 99999),
+		callback.New(HISTORY_SEND_ACTION, 9999999999),
+		callback.New(FORMAT_SET_ACTION, VOICE_FORMAT),
+		callback.New(SETTINGS_CHANGE_ACTION, EMOTION_SETTING, "reset"),
+		callback.New(SETTINGS_RESET_ACTION),
+		callback.New(LANGUAGE_SET_ACTION, templates.EN),
+	}
+
+	dispatcher := (&TgBot{}).newCallbacks()
+	codec := callback.NewCodec("secret", time.Hour)
+
+	for _, p := range payloads {
+		_, err := dispatcher.Handler(p)
+		if err != nil {
+			t.Fatalf("payload of %v doesn't match its handler: %v", p.Action, err)
+		}
+
+		_, err = codec.Encode(-1000000000000, p)
+		if err != nil {
+			t.Fatalf("payload of %v doesn't fit: %v", p.Action, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Quiexx/narrator-bot/internal/command"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newCommands registers commands in the order they are listed in /help and
+// the command menu.
+func (b *TgBot) newCommands() *command.Router {
+	router := command.NewRouter(b.bot.Self.UserName)
+	router.Use(b.interruptFlows)
+
+	router.Register(
+		&command.Command{
+			Name:        START_COMMAND,
+			Description: templates.START_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleStart,
+		},
+		&command.Command{
+			Name:        HELP_COMMAND,
+			Description: templates.HELP_COMMAND_DESCRIPTION,
+			Chats:       command.ALL_CHATS,
+			Handler:     b.handleHelp,
+		},
+		&command.Command{
+			Name:        API_KEY_COMMAND,
+			Description: templates.API_KEY_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleApiKey,
+		},
+		&command.Command{
+			Name:        VOICE_COMMAND,
+			Aliases:     []string{"voices"},
+			Description: templates.VOICE_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleVoice,
+		},
+		&command.Command{
+			Name:        GET_SYMBOLS_COMMAND,
+			Aliases:     []string{"balance"},
+			Description: templates.GET_SYMBOLS_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleGetSymbols,
+		},
+		&command.Command{
+			Name:        HISTORY_COMMAND,
+			Description: templates.HISTORY_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleHistory,
+		},
+		&command.Command{
+			Name:        TARIFFS_COMMAND,
+			Description: templates.TARIFFS_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleTariffs,
+		},
+		&command.Command{
+			Name:        FORMAT_COMMAND,
+			Description: templates.FORMAT_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleFormat,
+		},
+		&command.Command{
+			Name:        SETTINGS_COMMAND,
+			Description: templates.SETTINGS_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleSettings,
+		},
+		&command.Command{
+			Name:        LANGUAGE_COMMAND,
+			Aliases:     []string{"lang"},
+			Description: templates.LANGUAGE_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleLanguage,
+		},
+		&command.Command{
+			Name:        CANCEL_COMMAND,
+			Description: templates.CANCEL_COMMAND_DESCRIPTION,
+			Chats:       command.PRIVATE_CHATS,
+			Handler:     b.handleCancel,
+		},
+		&command.Command{
+			Name:        SAY_COMMAND,
+			Description: templates.SAY_COMMAND_DESCRIPTION,
+			Chats:       command.GROUP_CHATS,
+			Handler:     b.handleSay,
+		},
+		&command.Command{
+			Name:        CHAT_KEY_COMMAND,
+			Description: templates.CHAT_KEY_COMMAND_DESCRIPTION,
+			Chats:       command.GROUP_CHATS,
+			Handler:     b.handleChatKey,
+		},
+		&command.Command{
+			Name:    BLOCK_COMMAND,
+			Chats:   command.PRIVATE_CHATS,
+			Hidden:  true,
+			Handler: b.handleBlock,
+		},
+		&command.Command{
+			Name:    UNBLOCK_COMMAND,
+			Chats:   command.PRIVATE_CHATS,
+			Hidden:  true,
+			Handler: b.handleUnblock,
+		},
+	)
+
+	return router
+}
+
+func (b *TgBot) handleCommand(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	cmd, err := b.commands.Route(update.Message)
+	if errors.Is(err, command.ErrNotCommand) || errors.Is(err, command.ErrOtherBot) {
+		return
+	}
+
+	countCommand(cmd)
+
+	switch {
+	case err == nil:
+		b.commands.Handler(cmd)(ctx, update, tgUser)
+	case errors.Is(err, command.ErrGroupOnly):
+		b.sendMessage(update, templates.Text(ctx, templates.GROUP_ONLY_COMMAND_MESSAGE))
+	case errors.Is(err, command.ErrPrivateOnly):
+		b.sendMessage(update, templates.Text(ctx, templates.PRIVATE_ONLY_COMMAND_MESSAGE))
+	case update.FromChat().IsPrivate():
+		// unknown commands in groups may be meant for other bots
+		b.sendMessage(update, templates.Text(ctx, templates.UNKNOWN_COMMAND_MESSAGE))
+	}
+}
+
+// interruptFlows ends the flow the user is in when they call another command.
+func (b *TgBot) interruptFlows(cmd *command.Command, next command.Handler) command.Handler {
+	if cmd.Name == CANCEL_COMMAND {
+		return next
+	}
+
+	return func(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+		if update.FromChat().IsPrivate() {
+			b.resetState(ctx, tgUser)
+		}
+		next(ctx, update, tgUser)
+	}
+}
+
+func (b *TgBot) handleHelp(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	chats := command.GROUP_CHATS
+	if update.FromChat().IsPrivate() {
+		chats = command.PRIVATE_CHATS
+	}
+
+	lines := []string{}
+	for _, cmd := range b.commands.Visible(chats) {
+		line := fmt.Sprintf("/%v — %v", cmd.Name, templates.Text(ctx, cmd.Description))
+		if len(cmd.Aliases) != 0 {
+			line += fmt.Sprintf(templates.Text(ctx, templates.HELP_ALIASES), "/"+strings.Join(cmd.Aliases, ", /"))
+		}
+		lines = append(lines, line)
+	}
+
+	b.sendMessage(update, fmt.Sprintf(templates.Text(ctx, templates.HELP_MESSAGE), strings.Join(lines, "\n")))
+}
+
+// SetCommands makes the telegram command menu list the visible commands of
+// private and group chats in each language. Users of other languages see the
+// menu in FALLBACK_LANGUAGE.
+func (b *TgBot) SetCommands() error {
+	scopes := []struct {
+		scope tgbotapi.BotCommandScope
+		chats command.Chats
+	}{
+		{tgbotapi.NewBotCommandScopeAllPrivateChats(), command.PRIVATE_CHATS},
+		{tgbotapi.NewBotCommandScopeAllGroupChats(), command.GROUP_CHATS},
+	}
+
+	for _, scope := range scopes {
+		for _, lang := range append(templates.Languages(), "") {
+			descriptionLang := lang
+			if lang == "" {
+				descriptionLang = templates.FALLBACK_LANGUAGE
+			}
+
+			commands := []tgbotapi.BotCommand{}
+			for _, cmd := range b.commands.Visible(scope.chats) {
+				commands = append(commands, tgbotapi.BotCommand{
+					Command:     cmd.Name,
+					Description: templates.Lookup(descriptionLang, cmd.Description),
+				})
+			}
+
+			_, err := b.request(tgbotapi.NewSetMyCommandsWithScopeAndLanguage(scope.scope, lang, commands...))
+			if err != nil {
+				return fmt.Errorf("set %v commands in %q: %w", scope.scope.Type, lang, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestPrivateCommandToOtherBot(t *testing.T) {
+	b, _ := newTestBot(t, nil)
+	b.commands = b.newCommands()
+
+	tests := []struct {
+		text     string
+		command  bool
+		otherBot bool
+	}{
+		{text: "/start", command: true},
+		{text: "/start@Test_Bot", command: true},
+		{text: "/start@otherbot", otherBot: true},
+		{text: "hello"},
+	}
+
+	for _, test := range tests {
+		update := newTestUpdate(test.text)
+		if strings.HasPrefix(test.text, "/") {
+			update.Message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(test.text)}}
+		}
+
+		if b.messageIsCommand(update) != test.command || b.commandToOtherBot(update) != test.otherBot {
+			t.Fatalf("%q: got command %v, other bot %v", test.text, b.messageIsCommand(update), b.commandToOtherBot(update))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// VOICE_FORMAT is delivered as a voice message synthesized in VOICE_PROVIDER_FORMAT,
+	// other formats are the provider's formats delivered as files.
+	VOICE_FORMAT          = "voice"
+	VOICE_PROVIDER_FORMAT = "mp3"
+	MP3_FORMAT            = "mp3"
+)
+
+func userFormat(tgUser *model.TgUser) string {
+	if tgUser.AudioFormat == "" {
+		return VOICE_FORMAT
+	}
+	return tgUser.AudioFormat
+}
+
+func providerFormat(format string) string {
+	if format == VOICE_FORMAT {
+		return VOICE_PROVIDER_FORMAT
+	}
+	return format
+}
+
+// newAudioMessage sends voice messages with sendVoice, mp3 with sendAudio so it
+// can be played in the telegram player, and other formats with sendDocument.
+func newAudioMessage(chatId int64, replyToMessageId int, format string, file tgbotapi.RequestFileData, caption string) tgbotapi.Chattable {
+	switch format {
+	case VOICE_FORMAT:
+		msg := tgbotapi.NewVoice(chatId, file)
+		msg.ReplyToMessageID = replyToMessageId
+		msg.Caption = caption
+		return msg
+	case MP3_FORMAT:
+		msg := tgbotapi.NewAudio(chatId, file)
+		msg.ReplyToMessageID = replyToMessageId
+		msg.Caption = caption
+		return msg
+	default:
+		msg := tgbotapi.NewDocument(chatId, file)
+		msg.ReplyToMessageID = replyToMessageId
+		msg.Caption = caption
+		return msg
+	}
+}
+
+func sentFileId(msg *tgbotapi.Message) string {
+	switch {
+	case msg.Voice != nil:
+		return msg.Voice.FileID
+	case msg.Audio != nil:
+		return msg.Audio.FileID
+	case msg.Document != nil:
+		return msg.Document.FileID
+	default:
+		return ""
+	}
+}
+
+func (b *TgBot) handleFormat(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	b.sendMessageWithKeyboard(update, templates.Text(ctx, templates.FORMAT_LIST_MESSAGE), b.formatKeyboard(ctx, update, tgUser))
+}
+
+func (b *TgBot) handleFormatCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	if !b.supportsFormat(p.String(0)) {
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	tgUser.AudioFormat = p.String(0)
+
+	err := b.tgUserRep.UpdateUser(ctx, tgUser)
+	if err != nil {
+		b.log(ctx).Error("failed to update user format", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	b.editMessageWithKeyboard(update, b.formatKeyboard(ctx, update, tgUser), update.CallbackQuery.Message.MessageID)
+	b.sendMessage(update, templates.Text(ctx, templates.FORMAT_IS_SET_MESSAGE))
+}
+
+func (b *TgBot) formatKeyboard(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) tgbotapi.InlineKeyboardMarkup {
+	current := userFormat(tgUser)
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	for _, format := range b.formats() {
+		text := templates.Text(ctx, templates.VOICE_FORMAT_BUTTON)
+		if format != VOICE_FORMAT {
+			text = fmt.Sprintf(templates.Text(ctx, templates.FILE_FORMAT_BUTTON), format)
+		}
+		if format == current {
+			text = "✅ " + text
+		}
+
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+			b.callbackButton(update, text, callback.New(FORMAT_SET_ACTION, format)),
+		})
+	}
+
+	return callbackKeyboard(keyboard...)
+}
+
+func (b *TgBot) formats() []string {
+	return append([]string{VOICE_FORMAT}, b.provider.Formats()...)
+}
+
+func (b *TgBot) supportsFormat(format string) bool {
+	for _, supported := range b.formats() {
+		if format == supported {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const CHAT_KEY_OFF_ARGUMENT = "off"
+
+// handleGroupUpdate narrates messages in group chats only when asked to:
+// with /say, by mentioning the bot or by replying to its message.
+func (b *TgBot) handleGroupUpdate(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	if update.CallbackQuery != nil {
+		b.handleCallback(ctx, update, tgUser)
+		return
+	}
+
+	msg := update.Message
+	if msg == nil {
+		return
+	}
+
+	text, _ := messageText(msg)
+
+	switch {
+	case b.messageIsCommand(update):
+		b.handleCommand(ctx, update, tgUser)
+	case b.mentionsBot(msg):
+		b.narrateInChat(ctx, update, strings.TrimSpace(b.withoutMentions(msg)), true)
+	case b.repliesToBot(msg):
+		// people reply to the bot to talk to each other too, so the bot
+		// keeps quiet if it can't narrate the reply
+		b.narrateInChat(ctx, update, text, false)
+	}
+}
+
+// ignored reports whether the bot doesn't handle the update at all, like
+// group messages not addressed to it, so it costs no queries and no queue.
+func (b *TgBot) ignored(update *tgbotapi.Update) bool {
+	chat := update.FromChat()
+	if chat == nil || chat.IsPrivate() || update.CallbackQuery != nil {
+		return false
+	}
+
+	return update.Message == nil || !b.addressedToBot(update)
+}
+
+// addressedToBot reports whether a group message asks the bot to do something.
+func (b *TgBot) addressedToBot(update *tgbotapi.Update) bool {
+	return b.messageIsCommand(update) || b.mentionsBot(update.Message) || b.repliesToBot(update.Message)
+}
+
+func (b *TgBot) repliesToBot(msg *tgbotapi.Message) bool {
+	return msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil && msg.ReplyToMessage.From.ID == b.bot.Self.ID
+}
+
+func (b *TgBot) handleSay(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	msg := update.Message
+
+	text := strings.TrimSpace(msg.CommandArguments())
+	if text == "" && msg.ReplyToMessage != nil {
+		text = msg.ReplyToMessage.Text
+		if text == "" {
+			text = msg.ReplyToMessage.Caption
+		}
+	}
+
+	if text == "" {
+		b.sendMessage(update, templates.Text(ctx, templates.SAY_USAGE_MESSAGE))
+		return
+	}
+
+	b.narrateInChat(ctx, update, text, true)
+}
+
+func (b *TgBot) handleChatKey(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	chatId := update.FromChat().ID
+
+	isAdmin, err := b.isChatAdmin(chatId, update.SentFrom().ID)
+	if err != nil {
+		b.log(ctx).Error("failed to get chat member", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	if !isAdmin {
+		b.sendMessage(update, templates.Text(ctx, templates.CHAT_ADMIN_ONLY_MESSAGE))
+		return
+	}
+
+	if strings.TrimSpace(update.Message.CommandArguments()) == CHAT_KEY_OFF_ARGUMENT {
+		err = b.chatSettingsRep.Delete(ctx, chatId)
+		if err != nil {
+			b.log(ctx).Error("failed to delete chat settings", "error", err)
+			b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+			return
+		}
+		b.sendMessage(update, templates.Text(ctx, templates.CHAT_KEY_REMOVED_MESSAGE))
+		return
+	}
+
+	if tgUser.SteosvoiceApiKey == "" || tgUser.VoiceId == -1 {
+		b.sendMessage(update, templates.Text(ctx, templates.CHAT_OWNER_NO_API_KEY))
+		return
+	}
+
+	err = b.chatSettingsRep.Save(ctx, &model.ChatSettings{
+		ChatId:  chatId,
+		OwnerID: tgUser.ID,
+		VoiceId: tgUser.VoiceId,
+	})
+	if err != nil {
+		b.log(ctx).Error("failed to save chat settings", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	b.sendMessage(update, templates.Text(ctx, templates.CHAT_KEY_IS_SET_MESSAGE))
+}
+
+// narrateInChat narrates text with the api key and voice chosen for the chat.
+// Users who asked the bot directly are told if the chat has none.
+func (b *TgBot) narrateInChat(ctx context.Context, update *tgbotapi.Update, text string, asked bool) {
+	if text == "" {
+		return
+	}
+
+	settings, err := b.chatSettingsRep.Get(ctx, update.FromChat().ID)
+	if err != nil {
+		b.log(ctx).Error("failed to get chat settings", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	if settings == nil {
+		if asked {
+			b.sendMessage(update, templates.Text(ctx, templates.CHAT_NOT_CONFIGURED_MESSAGE))
+		}
+		return
+	}
+
+	owner, err := b.tgUserRep.GetById(ctx, settings.OwnerID)
+	if err != nil {
+		b.log(ctx).Error("failed to get chat owner", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+	owner.VoiceId = settings.VoiceId
+
+	update.Message.Text = text
+	b.synthesize(ctx, update, owner)
+}
+
+func (b *TgBot) mentionsBot(msg *tgbotapi.Message) bool {
+	text, entities := messageText(msg)
+
+	for _, ent := range entities {
+		if b.isBotMention(text, ent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withoutMentions returns the text of msg with mentions of the bot cut out.
+func (b *TgBot) withoutMentions(msg *tgbotapi.Message) string {
+	text, entities := messageText(msg)
+	units := utf16.Encode([]rune(text))
+
+	var builder strings.Builder
+	last := 0
+	for _, ent := range entities {
+		if !b.isBotMention(text, ent) || ent.Offset < last || ent.Offset+ent.Length > len(units) {
+			continue
+		}
+		builder.WriteString(string(utf16.Decode(units[last:ent.Offset])))
+		last = ent.Offset + ent.Length
+	}
+	builder.WriteString(string(utf16.Decode(units[last:])))
+
+	return builder.String()
+}
+
+// isBotMention compares usernames case-insensitively, like telegram does.
+func (b *TgBot) isBotMention(text string, ent tgbotapi.MessageEntity) bool {
+	switch ent.Type {
+	case "mention":
+		return strings.EqualFold(entityText(text, ent), "@"+b.bot.Self.UserName)
+	case "text_mention":
+		return ent.User != nil && ent.User.ID == b.bot.Self.ID
+	}
+	return false
+}
+
+func messageText(msg *tgbotapi.Message) (string, []tgbotapi.MessageEntity) {
+	if msg.Text == "" {
+		return msg.Caption, msg.CaptionEntities
+	}
+	return msg.Text, msg.Entities
+}
+
+// entityText cuts ent out of text. Offsets of entities are in utf-16 code
+// units.
+func entityText(text string, ent tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	if ent.Offset < 0 || ent.Length < 0 || ent.Offset+ent.Length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[ent.Offset : ent.Offset+ent.Length]))
+}
+
+func (b *TgBot) isChatAdmin(chatId int64, userId int64) (bool, error) {
+	member, err := b.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatId, UserID: userId},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return member.IsCreator() || member.IsAdministrator(), nil
+}
@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMentionsBot(t *testing.T) {
+	b, _ := newTestBot(t, nil)
+
+	tests := []struct {
+		name     string
+		text     string
+		offset   int
+		length   int
+		mentions bool
+		without  string
+	}{
+		{name: "exact", text: "@test_bot hello", offset: 0, length: 9, mentions: true, without: " hello"},
+		{name: "other case", text: "hi @Test_Bot", offset: 3, length: 9, mentions: true, without: "hi "},
+		{name: "after emoji", text: "😀 @TEST_BOT hi", offset: 3, length: 9, mentions: true, without: "😀  hi"},
+		{name: "other bot", text: "@other_bot hello", offset: 0, length: 10, without: "@other_bot hello"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := &tgbotapi.Message{
+				Text:     test.text,
+				Entities: []tgbotapi.MessageEntity{{Type: "mention", Offset: test.offset, Length: test.length}},
+			}
+
+			if got := b.mentionsBot(msg); got != test.mentions {
+				t.Fatalf("got %v, want %v", got, test.mentions)
+			}
+			if got := b.withoutMentions(msg); got != test.without {
+				t.Fatalf("got %q, want %q", got, test.without)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/health"
+	"github.com/Quiexx/narrator-bot/internal/metrics"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// TelegramCheck reports whether the bot api accepts the bot token.
+func (b *TgBot) TelegramCheck() health.Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		start := time.Now()
+		me, err := b.getMe(ctx)
+		metrics.Since(metrics.TelegramRequestDuration.WithLabelValues("getMe", telegramResult(err)), start)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"username": me.UserName}, nil
+	}
+}
+
+// getMe is bound to ctx, unlike the api client, so a hanging bot api fails
+// the check instead of leaving the request behind.
+func (b *TgBot) getMe(ctx context.Context) (*tgbotapi.User, error) {
+	url := fmt.Sprintf(b.apiEndpoint, b.bot.Token, "getMe")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.bot.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp tgbotapi.APIResponse
+	err = json.NewDecoder(resp.Body).Decode(&apiResp)
+	if err != nil {
+		return nil, fmt.Errorf("decode getMe response: %w", err)
+	}
+	if !apiResp.Ok {
+		return nil, &tgbotapi.Error{Code: apiResp.ErrorCode, Message: apiResp.Description}
+	}
+
+	var me tgbotapi.User
+	err = json.Unmarshal(apiResp.Result, &me)
+	if err != nil {
+		return nil, fmt.Errorf("decode getMe result: %w", err)
+	}
+	return &me, nil
+}
+
+// UpdatesCheck fails if updates aren't received or if queued updates haven't
+// moved for stallTimeout. An idle bot with an empty queue is fine.
+func (b *TgBot) UpdatesCheck(stallTimeout time.Duration) health.Check {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		queued := b.pool.Stats().Queued
+		details := map[string]interface{}{"queued": queued}
+
+		received := b.lastReceivedAt.Load()
+		if received != 0 {
+			details["last_received"] = time.Unix(0, received)
+		}
+
+		processed := b.lastProcessedAt.Load()
+		if processed != 0 {
+			details["last_processed"] = time.Unix(0, processed)
+		}
+
+		if !b.receiving.Load() {
+			return details, errors.New("updates are not received")
+		}
+
+		progress := time.Unix(0, max(processed, b.startedAt.Load()))
+		if queued > 0 && time.Since(progress) > stallTimeout {
+			return details, fmt.Errorf("no update processed for %v", time.Since(progress).Round(time.Second))
+		}
+
+		return details, nil
+	}
+}
@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/tts/ttstest"
+)
+
+func TestTelegramCheck(t *testing.T) {
+	b, _ := newTestBot(t, &ttstest.Provider{})
+
+	details, err := b.TelegramCheck()(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details["username"] != "test_bot" {
+		t.Fatalf("got details %v", details)
+	}
+}
+
+func TestTelegramCheckTimeout(t *testing.T) {
+	b, _ := newTestBot(t, &ttstest.Provider{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	_, err := b.TelegramCheck()(ctx)
+	if err == nil {
+		t.Fatal("check passes after ctx is done")
+	}
+}
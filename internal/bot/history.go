@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	HISTORY_PREVIEW_LENGTH = 30
+	HISTORY_TIME_FORMAT    = "02.01.06 15:04"
+)
+
+// sendNarration sends the narrated audio and records it in the user's history.
+// It returns the telegram file id of the sent audio.
+func (b *TgBot) sendNarration(
+	ctx context.Context,
+	update *tgbotapi.Update,
+	tgUser *model.TgUser,
+	text string,
+	format string,
+	file tgbotapi.RequestFileData,
+	audioUrl string,
+	caption string,
+) (string, error) {
+	sent, err := b.sendAudio(update, format, file, caption)
+	if err != nil {
+		return "", err
+	}
+
+	fileId := sentFileId(&sent)
+	b.saveHistory(ctx, tgUser, text, format, audioUrl, fileId)
+
+	return fileId, nil
+}
+
+func (b *TgBot) saveHistory(ctx context.Context, tgUser *model.TgUser, text string, format string, audioUrl string, fileId string) {
+	record := &model.HistoryRecord{
+		TgUserID:  tgUser.ID,
+		TextHash:  textHash(text),
+		Preview:   preview(text, HISTORY_PREVIEW_LENGTH),
+		VoiceId:   tgUser.VoiceId,
+		CharCount: utf8.RuneCountInString(text),
+		AudioUrl:  audioUrl,
+		FileId:    fileId,
+		Format:    format,
+	}
+
+	err := b.historyRep.Create(ctx, record)
+	if err != nil {
+		b.log(ctx).Error("failed to save history record", "error", err)
+	}
+}
+
+func (b *TgBot) handleHistory(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	b.sendHistoryMarkup(ctx, update, tgUser, 1, HISTORY_PAGE_SIZE, false)
+}
+
+func (b *TgBot) handleHistoryPageCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	b.sendHistoryMarkup(ctx, update, tgUser, int(p.Int(0)), HISTORY_PAGE_SIZE, true)
+}
+
+func (b *TgBot) handleHistorySendCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	b.resendHistoryRecord(ctx, update, tgUser, uint(p.Int(0)))
+}
+
+func (b *TgBot) sendHistoryMarkup(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, page int, pageSize int, edit bool) {
+	records, count, err := b.historyRep.GetPage(ctx, tgUser.ID, (page-1)*pageSize, pageSize)
+	if err != nil {
+		b.log(ctx).Error("failed to get history", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	if count == 0 {
+		b.sendMessage(update, templates.Text(ctx, templates.EMPTY_HISTORY_MESSAGE))
+		return
+	}
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	for _, record := range records {
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+			b.callbackButton(update, fmt.Sprintf("%v %v", record.CreatedAt.Format(HISTORY_TIME_FORMAT), record.Preview), callback.New(HISTORY_SEND_ACTION, record.ID)),
+		})
+	}
+
+	navigation := []tgbotapi.InlineKeyboardButton{}
+
+	maxPage := int(math.Ceil(float64(count) / float64(pageSize)))
+
+	if page > 1 {
+		navigation = append(navigation, b.callbackButton(update, "<", callback.New(HISTORY_PAGE_ACTION, page-1)))
+	}
+
+	if page < maxPage {
+		navigation = append(navigation, b.callbackButton(update, ">", callback.New(HISTORY_PAGE_ACTION, page+1)))
+	}
+
+	keyboard = append(keyboard, navigation)
+
+	keyboardMarkup := callbackKeyboard(keyboard...)
+
+	text := fmt.Sprintf(
+		templates.Text(ctx, templates.PAGE_MESSAGE),
+		templates.Text(ctx, templates.HISTORY_LIST_MESSAGE),
+		page,
+		maxPage,
+	)
+
+	if !edit {
+		b.sendMessageWithKeyboard(update, text, keyboardMarkup)
+		return
+	}
+	b.editMessage(update, text, update.CallbackQuery.Message.MessageID)
+	b.editMessageWithKeyboard(update, keyboardMarkup, update.CallbackQuery.Message.MessageID)
+}
+
+func (b *TgBot) resendHistoryRecord(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, recordId uint) {
+	record, err := b.historyRep.Get(ctx, tgUser.ID, recordId)
+	if err != nil {
+		b.log(ctx).Error("failed to get history record", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	var file tgbotapi.RequestFileData = tgbotapi.FileID(record.FileId)
+	if record.FileId == "" {
+		file = tgbotapi.FileURL(record.AudioUrl)
+	}
+
+	format := record.Format
+	if format == "" {
+		format = VOICE_FORMAT
+	}
+
+	_, err = b.send(newAudioMessage(update.FromChat().ID, 0, format, file, record.Preview))
+	if err != nil {
+		b.log(ctx).Error("failed to send message", "error", err)
+	}
+}
+
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+func preview(text string, length int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length]) + "…"
+}
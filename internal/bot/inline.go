@@ -0,0 +1,177 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+	"github.com/Quiexx/narrator-bot/internal/tts"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	VOICE_START_PARAMETER = "voice"
+	START_PARAMETER       = "start"
+
+	INLINE_CACHE_TIME      = 300
+	INLINE_TITLE_LENGTH    = 40
+	INLINE_RESULT_ID_VOICE = "voice"
+)
+
+// inlineQueries remembers the latest inline query of each user, so only the
+// query the user stopped typing at is synthesized. Queries are pushed when
+// they are received, before they wait for a worker.
+type inlineQueries struct {
+	mu     sync.Mutex
+	latest map[int64]string
+}
+
+func newInlineQueries() *inlineQueries {
+	return &inlineQueries{latest: map[int64]string{}}
+}
+
+func (q *inlineQueries) push(tgUserId int64, queryId string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.latest[tgUserId] = queryId
+}
+
+func (q *inlineQueries) superseded(tgUserId int64, queryId string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.latest[tgUserId] != queryId
+}
+
+func (q *inlineQueries) isLatest(tgUserId int64, queryId string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.latest[tgUserId] != queryId {
+		return false
+	}
+
+	delete(q.latest, tgUserId)
+	return true
+}
+
+func (b *TgBot) handleInlineQuery(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	query := update.InlineQuery
+	text := strings.TrimSpace(query.Query)
+
+	switch {
+	case tgUser.SteosvoiceApiKey == "":
+		b.answerInlineQuery(query.ID, nil, templates.Text(ctx, templates.INLINE_NO_API_KEY), START_PARAMETER)
+		return
+	case tgUser.VoiceId == -1 || text == "":
+		b.answerInlineQuery(query.ID, nil, templates.Text(ctx, templates.INLINE_CHOOSE_VOICE), VOICE_START_PARAMETER)
+		return
+	}
+
+	fileId, ok, err := b.audioCache.Lookup(ctx, text, tgUser.VoiceId, VOICE_FORMAT, userSpeechParams(tgUser))
+	if err != nil {
+		b.log(ctx).Error("failed to lookup audio cache", "error", err)
+	}
+
+	if !ok && b.inlineChatId == 0 {
+		// only narrations cached before can be sent without a chat to upload to
+		b.answerInlineQuery(query.ID, nil, templates.Text(ctx, templates.INLINE_DISABLED), START_PARAMETER)
+		return
+	}
+
+	if !ok {
+		if b.inlineQueries.superseded(tgUser.TgId, query.ID) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.inlineDebounce):
+		}
+
+		if !b.inlineQueries.isLatest(tgUser.TgId, query.ID) {
+			return
+		}
+
+		fileId, err = b.synthesizeInline(ctx, tgUser, text)
+	}
+
+	switch {
+	case errors.Is(err, tts.ErrNotEnoughSymbols):
+		b.answerInlineQuery(query.ID, nil, templates.Text(ctx, templates.INLINE_NOT_ENOUGH_SYMBOLS), START_PARAMETER)
+		return
+	case err != nil:
+		b.log(ctx).Error("failed to synthesize inline query", "error", err)
+		b.answerInlineQuery(query.ID, nil, templates.Text(ctx, templates.INLINE_SOMETHING_GONE_WRONG), START_PARAMETER)
+		return
+	}
+
+	result := tgbotapi.NewInlineQueryResultCachedVoice(
+		INLINE_RESULT_ID_VOICE,
+		fileId,
+		fmt.Sprintf(templates.Text(ctx, templates.INLINE_RESULT_TITLE), preview(text, INLINE_TITLE_LENGTH)),
+	)
+
+	b.answerInlineQuery(query.ID, []interface{}{result}, templates.Text(ctx, templates.INLINE_CHOOSE_VOICE), VOICE_START_PARAMETER)
+}
+
+// synthesizeInline uploads synthesized audio to the inline chat to get a
+// telegram file id for the inline result.
+func (b *TgBot) synthesizeInline(ctx context.Context, tgUser *model.TgUser, text string) (string, error) {
+	params := userSpeechParams(tgUser)
+
+	speech, err := b.synthesizeSpeech(ctx, tgUser, text, VOICE_PROVIDER_FORMAT)
+	if err != nil {
+		return "", err
+	}
+
+	sent, err := b.send(tgbotapi.NewVoice(b.inlineChatId, b.audioFile(ctx, speech.AudioUrl, VOICE_FORMAT)))
+	if err != nil {
+		return "", err
+	}
+
+	if sent.Voice == nil {
+		return "", errors.New("uploaded message has no voice")
+	}
+	fileId := sent.Voice.FileID
+
+	err = b.audioCache.Store(ctx, text, tgUser.VoiceId, VOICE_FORMAT, params, fileId)
+	if err != nil {
+		b.log(ctx).Error("failed to store audio cache", "error", err)
+	}
+
+	b.saveHistory(ctx, tgUser, text, VOICE_FORMAT, speech.AudioUrl, fileId)
+
+	return fileId, nil
+}
+
+// answerInlineQuery caches answers with results only. Answers without them
+// tell about errors, which must go away once the user fixes them, e.g. buys
+// symbols.
+func (b *TgBot) answerInlineQuery(queryId string, results []interface{}, switchPMText string, switchPMParameter string) {
+	cacheTime := INLINE_CACHE_TIME
+	if len(results) == 0 {
+		results = []interface{}{}
+		cacheTime = 0
+	}
+
+	_, err := b.request(tgbotapi.InlineConfig{
+		InlineQueryID:     queryId,
+		Results:           results,
+		CacheTime:         cacheTime,
+		IsPersonal:        true,
+		SwitchPMText:      switchPMText,
+		SwitchPMParameter: switchPMParameter,
+	})
+	if err != nil {
+		b.logger.Error("failed to answer inline query", "error", err)
+	}
+}
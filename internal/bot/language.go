@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// AUTO_LANGUAGE makes the bot follow the language of the user's telegram client.
+const AUTO_LANGUAGE = "auto"
+
+// updateLanguage is the language of the telegram client the update is sent from.
+func updateLanguage(update *tgbotapi.Update) string {
+	from := update.SentFrom()
+	if from == nil {
+		return templates.DEFAULT_LANGUAGE
+	}
+	return templates.Match(from.LanguageCode)
+}
+
+// localized picks a value in lang from values keyed by upper case language
+// codes, as the provider names voices and tariffs, or in any other language.
+func localized(values map[string]string, lang string) (string, bool) {
+	for _, key := range []string{strings.ToUpper(lang), strings.ToUpper(templates.FALLBACK_LANGUAGE), strings.ToUpper(templates.DEFAULT_LANGUAGE)} {
+		if value, ok := values[key]; ok && value != "" {
+			return value, true
+		}
+	}
+
+	for _, value := range values {
+		if value != "" {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+func (b *TgBot) handleLanguage(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	b.sendMessageWithKeyboard(update, templates.Text(ctx, templates.LANGUAGE_LIST_MESSAGE), b.languageKeyboard(ctx, update, tgUser))
+}
+
+func (b *TgBot) handleLanguageCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	if p.String(0) != AUTO_LANGUAGE && !templates.Supported(p.String(0)) {
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	tgUser.Language = p.String(0)
+	if tgUser.Language == AUTO_LANGUAGE {
+		tgUser.Language = ""
+	}
+
+	err := b.tgUserRep.UpdateUser(ctx, tgUser)
+	if err != nil {
+		b.log(ctx).Error("failed to update user language", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	ctx = templates.WithLanguage(ctx, updateLanguage(update))
+	if tgUser.Language != "" {
+		ctx = templates.WithLanguage(ctx, tgUser.Language)
+	}
+
+	b.editMessage(update, templates.Text(ctx, templates.LANGUAGE_LIST_MESSAGE), update.CallbackQuery.Message.MessageID)
+	b.editMessageWithKeyboard(update, b.languageKeyboard(ctx, update, tgUser), update.CallbackQuery.Message.MessageID)
+	b.sendMessage(update, templates.Text(ctx, templates.LANGUAGE_IS_SET_MESSAGE))
+}
+
+func (b *TgBot) languageKeyboard(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) tgbotapi.InlineKeyboardMarkup {
+	current := tgUser.Language
+	if current == "" {
+		current = AUTO_LANGUAGE
+	}
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	for _, lang := range append(templates.Languages(), AUTO_LANGUAGE) {
+		text := templates.LanguageName(lang)
+		if lang == AUTO_LANGUAGE {
+			text = templates.Text(ctx, templates.LANGUAGE_AUTO_BUTTON)
+		}
+		if lang == current {
+			text = "✅ " + text
+		}
+
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+			b.callbackButton(update, text, callback.New(LANGUAGE_SET_ACTION, lang)),
+		})
+	}
+
+	return callbackKeyboard(keyboard...)
+}
@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Quiexx/narrator-bot/internal/logging"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// log returns the logger with fields of the update handled with ctx.
+func (b *TgBot) log(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, b.logger)
+}
+
+func (b *TgBot) updateLogger(update *tgbotapi.Update) *slog.Logger {
+	attrs := []interface{}{"update_id", update.UpdateID, "update_type", updateType(update)}
+
+	if from := update.SentFrom(); from != nil {
+		attrs = append(attrs, "user_id", from.ID)
+	}
+
+	if chat := update.FromChat(); chat != nil {
+		attrs = append(attrs, "chat_id", chat.ID)
+	}
+
+	if update.Message != nil && update.Message.IsCommand() {
+		attrs = append(attrs, "command", update.Message.Command())
+	}
+
+	return b.logger.With(attrs...)
+}
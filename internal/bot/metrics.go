@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Quiexx/narrator-bot/internal/command"
+	"github.com/Quiexx/narrator-bot/internal/metrics"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func updateType(update *tgbotapi.Update) string {
+	switch {
+	case update.Message != nil:
+		return "message"
+	case update.EditedMessage != nil:
+		return "edited_message"
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	case update.InlineQuery != nil:
+		return "inline_query"
+	case update.ChosenInlineResult != nil:
+		return "chosen_inline_result"
+	case update.MyChatMember != nil:
+		return "my_chat_member"
+	default:
+		return "other"
+	}
+}
+
+// countCommand labels unknown commands alike, so users can't blow up the
+// number of series.
+func countCommand(cmd *command.Command) {
+	if cmd == nil {
+		metrics.Commands.WithLabelValues("unknown").Inc()
+		return
+	}
+	metrics.Commands.WithLabelValues("/" + cmd.Name).Inc()
+}
+
+// requestKind labels metrics with the config type, e.g. MessageConfig,
+// since tgbotapi doesn't export the api method of a config.
+func requestKind(c tgbotapi.Chattable) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", c), "tgbotapi.")
+}
+
+func telegramResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// narrations keeps abort channels of multi-part narrations in progress.
+type narrations struct {
+	mu     sync.Mutex
+	aborts map[string]chan struct{}
+}
+
+func newNarrations() *narrations {
+	return &narrations{aborts: map[string]chan struct{}{}}
+}
+
+func narrationKey(chatId int64, messageId int) string {
+	return fmt.Sprintf("%v_%v", chatId, messageId)
+}
+
+func (n *narrations) start(key string) <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	abort := make(chan struct{})
+	n.aborts[key] = abort
+	return abort
+}
+
+func (n *narrations) finish(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.aborts, key)
+}
+
+func (n *narrations) abort(key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	abort, ok := n.aborts[key]
+	if !ok {
+		return false
+	}
+
+	close(abort)
+	delete(n.aborts, key)
+	return true
+}
+
+// narrate synthesizes chunks one by one and sends them as numbered voice
+// messages replying to the original message. The progress message has a
+// button to abort the narration between chunks.
+func (b *TgBot) narrate(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, chunks []string) {
+	key := narrationKey(update.FromChat().ID, update.Message.MessageID)
+	abort := b.narrations.start(key)
+	defer b.narrations.finish(key)
+
+	keyboardMarkup := callbackKeyboard(
+		[]tgbotapi.InlineKeyboardButton{
+			b.callbackButton(update, templates.Text(ctx, templates.NARRATION_ABORT_BUTTON), callback.New(NARRATION_ABORT_ACTION, update.Message.MessageID)),
+		},
+	)
+
+	progress, err := b.sendMessageWithKeyboard(
+		update,
+		fmt.Sprintf(templates.Text(ctx, templates.NARRATION_PROGRESS_MESSAGE), 0, len(chunks)),
+		keyboardMarkup,
+	)
+	if err != nil {
+		return
+	}
+
+	for i, chunk := range chunks {
+		select {
+		case <-abort:
+			b.editMessage(update, fmt.Sprintf(templates.Text(ctx, templates.NARRATION_ABORTED_MESSAGE), i, len(chunks)), progress.MessageID)
+			return
+		case <-ctx.Done():
+			b.editMessage(update, fmt.Sprintf(templates.Text(ctx, templates.NARRATION_ABORTED_MESSAGE), i, len(chunks)), progress.MessageID)
+			return
+		default:
+		}
+
+		ok := b.narrateText(ctx, update, tgUser, chunk, fmt.Sprintf("%v/%v", i+1, len(chunks)))
+		if !ok {
+			b.editMessage(update, fmt.Sprintf(templates.Text(ctx, templates.NARRATION_FAILED_MESSAGE), i, len(chunks)), progress.MessageID)
+			return
+		}
+
+		if i+1 < len(chunks) {
+			b.editMessage(update, fmt.Sprintf(templates.Text(ctx, templates.NARRATION_PROGRESS_MESSAGE), i+1, len(chunks)), progress.MessageID)
+			b.editMessageWithKeyboard(update, keyboardMarkup, progress.MessageID)
+		}
+	}
+
+	b.editMessage(update, fmt.Sprintf(templates.Text(ctx, templates.NARRATION_DONE_MESSAGE), len(chunks)), progress.MessageID)
+}
+
+// narrateText sends text narrated with the user's voice. Audio narrated before
+// is sent by its file id without spending symbols.
+func (b *TgBot) narrateText(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, text string, caption string) bool {
+	format := userFormat(tgUser)
+	params := userSpeechParams(tgUser)
+
+	fileId, ok, err := b.audioCache.Lookup(ctx, text, tgUser.VoiceId, format, params)
+	if err != nil {
+		b.log(ctx).Error("failed to lookup audio cache", "error", err)
+	}
+
+	if ok {
+		_, err = b.sendNarration(ctx, update, tgUser, text, format, tgbotapi.FileID(fileId), "", caption)
+		if err == nil {
+			return true
+		}
+
+		err = b.audioCache.Invalidate(ctx, text, tgUser.VoiceId, format, params)
+		if err != nil {
+			b.log(ctx).Error("failed to invalidate audio cache", "error", err)
+		}
+	}
+
+	audioUrl, ok := b.synthesizeText(ctx, update, tgUser, text, format)
+	if !ok {
+		return false
+	}
+
+	fileId, err = b.sendNarration(ctx, update, tgUser, text, format, b.audioFile(ctx, audioUrl, format), audioUrl, caption)
+	if err != nil {
+		return false
+	}
+
+	if fileId == "" {
+		return true
+	}
+
+	err = b.audioCache.Store(ctx, text, tgUser.VoiceId, format, params, fileId)
+	if err != nil {
+		b.log(ctx).Error("failed to store audio cache", "error", err)
+	}
+
+	return true
+}
+
+// audioFile downloads synthesized audio to upload it to telegram, audio for
+// voice messages is encoded as well. If the audio can't be downloaded,
+// telegram fetches it by url itself.
+func (b *TgBot) audioFile(ctx context.Context, audioUrl string, format string) tgbotapi.RequestFileData {
+	data, err := b.audioFetcher.Fetch(ctx, audioUrl)
+	if err != nil {
+		b.log(ctx).Error("failed to fetch audio", "error", err)
+		return tgbotapi.FileURL(audioUrl)
+	}
+
+	fileFormat := providerFormat(format)
+	if format != VOICE_FORMAT {
+		return tgbotapi.FileBytes{Name: "narration." + fileFormat, Bytes: data}
+	}
+
+	encoded, encodedFormat, err := b.audioEncoder.Encode(ctx, data, fileFormat)
+	if err != nil {
+		b.log(ctx).Error("failed to encode audio", "error", err)
+		return tgbotapi.FileBytes{Name: "narration." + fileFormat, Bytes: data}
+	}
+
+	return tgbotapi.FileBytes{Name: "narration." + encodedFormat, Bytes: encoded}
+}
+
+func (b *TgBot) handleNarrationCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	b.narrations.abort(narrationKey(update.FromChat().ID, int(p.Int(0))))
+}
@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+	"github.com/Quiexx/narrator-bot/internal/tts"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	SPEED_SETTING   = "speed"
+	PITCH_SETTING   = "pitch"
+	VOLUME_SETTING  = "volume"
+	EMOTION_SETTING = "emotion"
+)
+
+func userSpeechParams(tgUser *model.TgUser) tts.SpeechParams {
+	return tts.SpeechParams{
+		Speed:   tgUser.SpeechSpeed,
+		Pitch:   tgUser.SpeechPitch,
+		Volume:  tgUser.SpeechVolume,
+		Emotion: tgUser.SpeechEmotion,
+	}
+}
+
+func setUserSpeechParams(tgUser *model.TgUser, params tts.SpeechParams) {
+	tgUser.SpeechSpeed = params.Speed
+	tgUser.SpeechPitch = params.Pitch
+	tgUser.SpeechVolume = params.Volume
+	tgUser.SpeechEmotion = params.Emotion
+}
+
+func (b *TgBot) handleSettings(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	b.sendSettingsMarkup(ctx, update, tgUser, false)
+}
+
+func (b *TgBot) handleSettingsResetCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	b.saveSpeechParams(ctx, update, tgUser, tts.SpeechParams{})
+}
+
+func (b *TgBot) handleSettingsCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	limits := b.provider.ParamLimits()
+	params := userSpeechParams(tgUser)
+	action := p.String(1)
+
+	switch p.String(0) {
+	case SPEED_SETTING:
+		params.Speed = shiftParam(limits.Speed, params.Speed, action)
+	case PITCH_SETTING:
+		params.Pitch = shiftParam(limits.Pitch, params.Pitch, action)
+	case VOLUME_SETTING:
+		params.Volume = shiftParam(limits.Volume, params.Volume, action)
+	case EMOTION_SETTING:
+		params.Emotion = nextEmotion(limits.Emotions, params.Emotion)
+	default:
+		return
+	}
+
+	b.saveSpeechParams(ctx, update, tgUser, params)
+}
+
+func (b *TgBot) saveSpeechParams(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, params tts.SpeechParams) {
+	err := b.provider.ParamLimits().Validate(params)
+	if err != nil {
+		b.log(ctx).Error("failed to change speech params", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	setUserSpeechParams(tgUser, params)
+
+	err = b.tgUserRep.UpdateUser(ctx, tgUser)
+	if err != nil {
+		b.log(ctx).Error("failed to update user speech params", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	b.sendSettingsMarkup(ctx, update, tgUser, true)
+}
+
+func (b *TgBot) sendSettingsMarkup(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, edit bool) {
+	limits := b.provider.ParamLimits()
+	params := userSpeechParams(tgUser)
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	rangeSettings := []struct {
+		name  string
+		title string
+		limit tts.Range
+	}{
+		{SPEED_SETTING, templates.Text(ctx, templates.SETTINGS_SPEED_BUTTON), limits.Speed},
+		{PITCH_SETTING, templates.Text(ctx, templates.SETTINGS_PITCH_BUTTON), limits.Pitch},
+		{VOLUME_SETTING, templates.Text(ctx, templates.SETTINGS_VOLUME_BUTTON), limits.Volume},
+	}
+
+	for _, setting := range rangeSettings {
+		if !setting.limit.Supported() {
+			continue
+		}
+
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+			b.callbackButton(update, "−", callback.New(SETTINGS_CHANGE_ACTION, setting.name, "dec")),
+			b.callbackButton(update, setting.title, callback.New(SETTINGS_CHANGE_ACTION, setting.name, "reset")),
+			b.callbackButton(update, "+", callback.New(SETTINGS_CHANGE_ACTION, setting.name, "inc")),
+		})
+	}
+
+	if len(limits.Emotions) != 0 {
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+			b.callbackButton(update, fmt.Sprintf(templates.Text(ctx, templates.SETTINGS_EMOTION_BUTTON), settingValue(ctx, params.Emotion)), callback.New(SETTINGS_CHANGE_ACTION, EMOTION_SETTING, "next")),
+		})
+	}
+
+	keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+		b.callbackButton(update, templates.Text(ctx, templates.SETTINGS_RESET_BUTTON), callback.New(SETTINGS_RESET_ACTION)),
+	})
+
+	keyboardMarkup := callbackKeyboard(keyboard...)
+
+	text := fmt.Sprintf(
+		templates.Text(ctx, templates.SETTINGS_MESSAGE),
+		settingValue(ctx, params.Speed),
+		settingValue(ctx, params.Pitch),
+		settingValue(ctx, params.Volume),
+		settingValue(ctx, params.Emotion),
+	)
+
+	if !edit {
+		b.sendMessageWithKeyboard(update, text, keyboardMarkup)
+		return
+	}
+	b.editMessage(update, text, update.CallbackQuery.Message.MessageID)
+	b.editMessageWithKeyboard(update, keyboardMarkup, update.CallbackQuery.Message.MessageID)
+}
+
+func shiftParam(limit tts.Range, value float64, action string) float64 {
+	switch action {
+	case "inc":
+		return limit.Shift(value, 1)
+	case "dec":
+		return limit.Shift(value, -1)
+	default:
+		return 0
+	}
+}
+
+func nextEmotion(emotions []string, current string) string {
+	if current == "" && len(emotions) != 0 {
+		return emotions[0]
+	}
+
+	for i, emotion := range emotions {
+		if emotion == current && i+1 < len(emotions) {
+			return emotions[i+1]
+		}
+	}
+
+	return ""
+}
+
+func settingValue(ctx context.Context, value interface{}) interface{} {
+	if value == 0.0 || value == "" {
+		return templates.Text(ctx, templates.SETTINGS_DEFAULT_VALUE)
+	}
+	return value
+}
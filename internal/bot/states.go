@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/fsm"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// stateCommands are the commands starting flows, to call again when a flow
+// expires.
+var stateCommands = map[string]string{
+	SET_API_KEY_STATE: API_KEY_COMMAND,
+}
+
+// newStates declares the conversational flows of private chats. A flow
+// starts with a transition from DEFAULT_STATE and ends by resetting to it.
+func (b *TgBot) newStates(timeout time.Duration) *fsm.Machine {
+	return fsm.New(
+		&fsm.State{
+			Name: DEFAULT_STATE,
+			Next: []string{SET_API_KEY_STATE},
+		},
+		&fsm.State{
+			Name:    SET_API_KEY_STATE,
+			Handler: b.setUserApiKey,
+			Timeout: timeout,
+		},
+	)
+}
+
+// handleState passes the update to the handler of the user's state. It
+// reports false if the user isn't in a flow. The update that finds the flow
+// expired is handled by telling the user so, it may be an answer to the flow,
+// like an api key, that must not be narrated.
+func (b *TgBot) handleState(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) bool {
+	if b.states.Expired(tgUser, time.Now()) {
+		state := tgUser.State
+		b.log(ctx).Info("state expired", "state", state)
+		b.resetState(ctx, tgUser)
+
+		command, ok := stateCommands[state]
+		if !ok {
+			// states of older versions
+			return false
+		}
+		b.sendMessage(update, fmt.Sprintf(templates.Text(ctx, templates.STATE_EXPIRED_MESSAGE), command))
+		return true
+	}
+
+	state := b.states.Current(tgUser)
+	if state.Handler == nil {
+		return false
+	}
+
+	state.Handler(ctx, update, tgUser)
+	return true
+}
+
+func (b *TgBot) enterState(ctx context.Context, tgUser *model.TgUser, state string, payload interface{}) error {
+	err := b.states.Transition(tgUser, state, payload)
+	if err != nil {
+		return err
+	}
+
+	return b.tgUserRep.UpdateUser(ctx, tgUser)
+}
+
+// resetState ends the flow the user is in. It reports false if there was none.
+func (b *TgBot) resetState(ctx context.Context, tgUser *model.TgUser) bool {
+	if !b.states.Reset(tgUser) {
+		return false
+	}
+
+	err := b.tgUserRep.UpdateUser(ctx, tgUser)
+	if err != nil {
+		b.log(ctx).Error("failed to reset user state", "error", err)
+	}
+	return true
+}
+
+func (b *TgBot) handleCancel(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	if !b.resetState(ctx, tgUser) {
+		b.sendMessage(update, templates.Text(ctx, templates.NOTHING_TO_CANCEL_MESSAGE))
+		return
+	}
+	b.sendMessage(update, templates.Text(ctx, templates.CANCELLED_MESSAGE))
+}
@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (b *TgBot) handleTariffs(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	if tgUser.SteosvoiceApiKey == "" {
+		b.sendMessage(update, templates.Text(ctx, templates.NO_API_KEY_MESSAGE))
+		return
+	}
+
+	tariffs, err := b.provider.Tariffs(ctx, tgUser.SteosvoiceApiKey)
+	if err != nil {
+		b.log(ctx).Error("failed to get tariffs", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.FAIL_COMMAND_MESSAGE))
+		return
+	}
+
+	symbols, err := b.provider.Balance(ctx, tgUser.SteosvoiceApiKey)
+	if err != nil {
+		b.log(ctx).Error("failed to get symbols count", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.FAIL_COMMAND_MESSAGE))
+		return
+	}
+
+	if len(tariffs) == 0 {
+		b.sendMessage(update, templates.Text(ctx, templates.NO_TARIFFS_MESSAGE))
+		return
+	}
+
+	lines := make([]string, 0, len(tariffs))
+	for _, tariff := range tariffs {
+		name, ok := localized(tariff.Name, templates.Language(ctx))
+		if !ok {
+			name = fmt.Sprint(tariff.Id)
+		}
+
+		lines = append(lines, fmt.Sprintf(templates.Text(ctx, templates.TARIFF_LINE), name, tariff.Price, tariff.Currency, tariff.Symbols))
+	}
+
+	keyboardMarkup := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(templates.Text(ctx, templates.BUY_SYMBOLS_BUTTON), templates.BUY_SYMBOLS_URL),
+		),
+	)
+
+	b.sendMessageWithKeyboard(
+		update,
+		fmt.Sprintf(templates.Text(ctx, templates.TARIFFS_MESSAGE), strings.Join(lines, "\n"), symbols),
+		keyboardMarkup,
+	)
+}
@@ -0,0 +1,477 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Quiexx/narrator-bot/internal/callback"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/templates"
+	"github.com/Quiexx/narrator-bot/internal/tts"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	VOICE_PAGE_SIZE        = 5
+	VOICE_CHIPS_PER_ROW    = 3
+	VOICE_FILTER_FIELDS    = 4
+	VOICE_FILTER_SEPARATOR = ","
+
+	// VOICE_QUERY_LENGTH is the most bytes of a /voice query buttons keep, so
+	// the filter fits in callback data. The longest payload, voice.info with
+	// a 6 digit voice id, a 3 digit page and a 2 digit language, leaves 14
+	// bytes of 64 for the query.
+	VOICE_QUERY_LENGTH = 14
+)
+
+var langs = map[int64]string{
+	1: "RU",
+	2: "EN",
+}
+
+// voiceFilter narrows the voice list down. Buttons of the list carry it, so
+// it keeps its filters while the user pages through it.
+type voiceFilter struct {
+	Sex       string
+	LangId    int64
+	Favorites bool
+	Query     string
+
+	// cut tells that Query is shorter than the query of the user, it isn't
+	// encoded
+	cut bool
+}
+
+// newVoiceFilter searches voices by query, cut to fit in callback data.
+func newVoiceFilter(query string) *voiceFilter {
+	f := &voiceFilter{Query: strings.TrimSpace(strings.ReplaceAll(query, callback.SEPARATOR, " "))}
+	for len(f.Query) > VOICE_QUERY_LENGTH {
+		_, size := utf8.DecodeLastRuneInString(f.Query)
+		f.Query = strings.TrimSpace(f.Query[:len(f.Query)-size])
+		f.cut = true
+	}
+	return f
+}
+
+// encode formats f as "<sex>,<lang id>,<favorites>,<query>". The sex is its
+// position in sexes, names of sexes would leave no room for the query.
+func (f *voiceFilter) encode(sexes []string) string {
+	sex := 0
+	for i, s := range sexes {
+		if s == f.Sex {
+			sex = i + 1
+		}
+	}
+
+	favorites := 0
+	if f.Favorites {
+		favorites = 1
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(sex),
+		strconv.FormatInt(f.LangId, 10),
+		strconv.Itoa(favorites),
+		f.Query,
+	}, VOICE_FILTER_SEPARATOR)
+}
+
+// decodeVoiceFilter parses data made by encode, malformed data filters nothing.
+func decodeVoiceFilter(data string, sexes []string) *voiceFilter {
+	f := &voiceFilter{}
+
+	fields := strings.SplitN(data, VOICE_FILTER_SEPARATOR, VOICE_FILTER_FIELDS)
+	if len(fields) != VOICE_FILTER_FIELDS {
+		return f
+	}
+
+	sex, _ := strconv.Atoi(fields[0])
+	if sex > 0 && sex <= len(sexes) {
+		f.Sex = sexes[sex-1]
+	}
+	f.LangId, _ = strconv.ParseInt(fields[1], 10, 64)
+	f.Favorites = fields[2] == "1"
+	f.Query = fields[3]
+
+	return f
+}
+
+func (f *voiceFilter) active() bool {
+	return f.Sex != "" || f.LangId != 0 || f.Favorites || f.Query != ""
+}
+
+func (f *voiceFilter) match(voice *tts.Voice, favorites map[int64]bool) bool {
+	if f.Sex != "" && voice.Sex != f.Sex {
+		return false
+	}
+	if f.LangId != 0 && voice.LangId != f.LangId {
+		return false
+	}
+	if f.Favorites && !favorites[voice.Id] {
+		return false
+	}
+	if f.Query == "" {
+		return true
+	}
+
+	query := strings.ToLower(f.Query)
+	for _, name := range voice.Name {
+		if strings.Contains(strings.ToLower(name), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func filterVoices(voices []*tts.Voice, f *voiceFilter, favorites map[int64]bool) []*tts.Voice {
+	filtered := []*tts.Voice{}
+	for _, voice := range voices {
+		if f.match(voice, favorites) {
+			filtered = append(filtered, voice)
+		}
+	}
+	return filtered
+}
+
+// voiceFacets returns the sexes and languages of voices, sorted, so chips and
+// positions of sexes in filters don't change between requests.
+func voiceFacets(voices []*tts.Voice) ([]string, []int64) {
+	sexes := []string{}
+	langIds := []int64{}
+	seenSexes := map[string]bool{}
+	seenLangIds := map[int64]bool{}
+
+	for _, voice := range voices {
+		if voice.Sex != "" && !seenSexes[voice.Sex] {
+			seenSexes[voice.Sex] = true
+			sexes = append(sexes, voice.Sex)
+		}
+		if voice.LangId != 0 && !seenLangIds[voice.LangId] {
+			seenLangIds[voice.LangId] = true
+			langIds = append(langIds, voice.LangId)
+		}
+	}
+
+	sort.Strings(sexes)
+	sort.Slice(langIds, func(i, j int) bool { return langIds[i] < langIds[j] })
+
+	return sexes, langIds
+}
+
+func langName(langId int64) string {
+	lang, ok := langs[langId]
+	if !ok {
+		return fmt.Sprint(langId)
+	}
+	return lang
+}
+
+func (b *TgBot) handleVoice(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser) {
+	b.openVoices(ctx, update, tgUser, newVoiceFilter(update.Message.CommandArguments()))
+}
+
+func (b *TgBot) openVoices(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, filter *voiceFilter) {
+	if tgUser.SteosvoiceApiKey == "" {
+		b.sendMessage(update, templates.Text(ctx, templates.NO_API_KEY_MESSAGE))
+		return
+	}
+
+	if filter.cut {
+		b.sendMessage(update, fmt.Sprintf(templates.Text(ctx, templates.VOICE_QUERY_CUT_MESSAGE), tgbotapi.EscapeText(tgbotapi.ModeMarkdown, filter.Query)))
+	}
+
+	// the filter has no sex yet, so it doesn't need sexes of the voices
+	b.updateUserVoices(ctx, tgUser)
+	b.sendVoicesMarkup(ctx, update, tgUser, filter.encode(nil), 1, false)
+}
+
+func (b *TgBot) handleVoicePageCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	b.sendVoicesMarkup(ctx, update, tgUser, p.String(1), int(p.Int(0)), true)
+}
+
+func (b *TgBot) handleVoiceInfoCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	b.sendVoiceDescription(ctx, update, tgUser, p.Int(0), int(p.Int(1)), p.String(2))
+}
+
+func (b *TgBot) handleVoiceSetCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	tgUser.VoiceId = p.Int(0)
+
+	err := b.tgUserRep.UpdateUser(ctx, tgUser)
+	if err != nil {
+		b.log(ctx).Error("failed to update user voice", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	b.sendMessage(update, templates.Text(ctx, templates.VOICE_IS_SET_MESSAGE))
+
+}
+
+func (b *TgBot) handleVoiceFavoriteCallback(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *callback.Payload) {
+	voiceId := p.Int(0)
+
+	favorites, err := b.favoriteVoices(ctx, tgUser)
+	if err == nil {
+		if favorites[voiceId] {
+			err = b.favoriteVoiceRep.Remove(ctx, tgUser.ID, voiceId)
+		} else {
+			err = b.favoriteVoiceRep.Add(ctx, tgUser.ID, voiceId)
+		}
+	}
+
+	if err != nil {
+		b.log(ctx).Error("failed to update favorite voices", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	b.sendVoiceDescription(ctx, update, tgUser, voiceId, int(p.Int(1)), p.String(2))
+}
+
+// sendVoicesMarkup sends the page of voices matching the encoded filter, with
+// chips toggling filters. Each chip tells how many voices its filter leaves.
+func (b *TgBot) sendVoicesMarkup(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, filterData string, page int, edit bool) {
+
+	voices, err := b.getUserVoices(ctx, tgUser)
+
+	if err != nil {
+		b.log(ctx).Error("failed to get voices", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	favorites, err := b.favoriteVoices(ctx, tgUser)
+	if err != nil {
+		b.log(ctx).Error("failed to get favorite voices", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	sexes, langIds := voiceFacets(voices)
+	filter := decodeVoiceFilter(filterData, sexes)
+	found := filterVoices(voices, filter, favorites)
+
+	maxPage := int(math.Ceil(float64(len(found)) / float64(VOICE_PAGE_SIZE)))
+	if maxPage == 0 {
+		maxPage = 1
+	}
+	if page < 1 {
+		page = 1
+	}
+	if page > maxPage {
+		page = maxPage
+	}
+
+	// chip counts the voices f leaves, pressing it applies f or, if the chip
+	// is selected, drops its filter
+	chip := func(text string, f voiceFilter, selected bool, unselected voiceFilter) tgbotapi.InlineKeyboardButton {
+		text = fmt.Sprintf("%v (%v)", text, len(filterVoices(voices, &f, favorites)))
+		if selected {
+			text = "✅ " + text
+			f = unselected
+		}
+		return b.callbackButton(update, text, callback.New(VOICE_PAGE_ACTION, 1, f.encode(sexes)))
+	}
+
+	chips := [][]tgbotapi.InlineKeyboardButton{}
+
+	if len(sexes) > 1 {
+		row := []tgbotapi.InlineKeyboardButton{}
+		unselected := *filter
+		unselected.Sex = ""
+		for _, sex := range sexes {
+			f := *filter
+			f.Sex = sex
+			row = append(row, chip(sex, f, filter.Sex == sex, unselected))
+		}
+		chips = append(chips, row)
+	}
+
+	if len(langIds) > 1 {
+		row := []tgbotapi.InlineKeyboardButton{}
+		unselected := *filter
+		unselected.LangId = 0
+		for _, langId := range langIds {
+			f := *filter
+			f.LangId = langId
+			row = append(row, chip(langName(langId), f, filter.LangId == langId, unselected))
+		}
+		chips = append(chips, row)
+	}
+
+	favorite, unfavorite := *filter, *filter
+	favorite.Favorites = true
+	unfavorite.Favorites = false
+	row := []tgbotapi.InlineKeyboardButton{
+		chip(templates.Text(ctx, templates.FAVORITE_VOICES_BUTTON), favorite, filter.Favorites, unfavorite),
+	}
+	if filter.active() {
+		row = append(row, b.callbackButton(update, templates.Text(ctx, templates.RESET_FILTERS_BUTTON), callback.New(VOICE_PAGE_ACTION, 1, (&voiceFilter{}).encode(sexes))))
+	}
+	chips = append(chips, row)
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+	for _, row := range chips {
+		for len(row) > VOICE_CHIPS_PER_ROW {
+			keyboard = append(keyboard, row[:VOICE_CHIPS_PER_ROW])
+			row = row[VOICE_CHIPS_PER_ROW:]
+		}
+		keyboard = append(keyboard, row)
+	}
+
+	encoded := filter.encode(sexes)
+	start := (page - 1) * VOICE_PAGE_SIZE
+
+	for i, voice := range found {
+		if i == start+VOICE_PAGE_SIZE {
+			break
+		}
+
+		if i >= start {
+			name, ok := localized(voice.Name, templates.Language(ctx))
+			if !ok {
+				name = fmt.Sprint(voice.Id)
+			}
+			if favorites[voice.Id] {
+				name = "★ " + name
+			}
+
+			keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+				b.callbackButton(update, name, callback.New(VOICE_INFO_ACTION, voice.Id, page, encoded)),
+			})
+		}
+	}
+
+	navigation := []tgbotapi.InlineKeyboardButton{}
+
+	if page != 1 {
+		navigation = append(navigation, b.callbackButton(update, "<", callback.New(VOICE_PAGE_ACTION, page-1, encoded)))
+	}
+
+	if page != maxPage {
+		navigation = append(navigation, b.callbackButton(update, ">", callback.New(VOICE_PAGE_ACTION, page+1, encoded)))
+	}
+
+	if len(navigation) != 0 {
+		keyboard = append(keyboard, navigation)
+	}
+
+	keyboardMarkup := callbackKeyboard(keyboard...)
+
+	text := fmt.Sprintf(
+		templates.Text(ctx, templates.PAGE_MESSAGE),
+		voiceListTitle(ctx, filter, len(found)),
+		page,
+		maxPage,
+	)
+
+	if !edit {
+		b.sendMessageWithKeyboard(update, text, keyboardMarkup)
+		return
+	}
+	b.editMessage(update, text, update.CallbackQuery.Message.MessageID)
+	b.editMessageWithKeyboard(update, keyboardMarkup, update.CallbackQuery.Message.MessageID)
+}
+
+// voiceListTitle tells how many voices filter found and what the user
+// searched for. The query is escaped, the list is sent as markdown.
+func voiceListTitle(ctx context.Context, filter *voiceFilter, found int) string {
+	title := templates.Text(ctx, templates.VOICE_LIST_MESSAGE) + "\n\n" + fmt.Sprintf(templates.Text(ctx, templates.VOICES_FOUND_MESSAGE), found)
+	if filter.Query != "" {
+		title += "\n" + fmt.Sprintf(templates.Text(ctx, templates.VOICE_QUERY_MESSAGE), tgbotapi.EscapeText(tgbotapi.ModeMarkdown, filter.Query))
+	}
+	return title
+}
+
+// sendVoiceDescription shows the voice, its buttons keep filterData to get
+// back to the same page of the list.
+func (b *TgBot) sendVoiceDescription(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, voiceId int64, page int, filterData string) {
+
+	voice, ok := b.voiceCache.Voice(ctx, tgUser.ID, tgUser.SteosvoiceApiKey, voiceId)
+
+	if !ok {
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	favorites, err := b.favoriteVoices(ctx, tgUser)
+	if err != nil {
+		b.log(ctx).Error("failed to get favorite voices", "error", err)
+		b.sendMessage(update, templates.Text(ctx, templates.SOMETHING_GONE_WRONG_MESSAGE))
+		return
+	}
+
+	name, ok := localized(voice.Name, templates.Language(ctx))
+	if !ok {
+		name = fmt.Sprint(voice.Id)
+	}
+
+	description, _ := localized(voice.Description, templates.Language(ctx))
+
+	sex := voice.Sex
+	if sex == "" {
+		sex = templates.Text(ctx, templates.UNKNOWN_VALUE)
+	}
+
+	lang, ok := langs[voice.LangId]
+	if !ok {
+		lang = templates.Text(ctx, templates.UNKNOWN_VALUE)
+	}
+
+	text := fmt.Sprintf(
+		templates.Text(ctx, templates.VOICE_DESCRIPTION_MESSAGE),
+		name,
+		description,
+		sex,
+		lang,
+	)
+
+	favoriteButton := templates.Text(ctx, templates.ADD_FAVORITE_BUTTON)
+	if favorites[voiceId] {
+		favoriteButton = templates.Text(ctx, templates.REMOVE_FAVORITE_BUTTON)
+	}
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{
+		{
+			b.callbackButton(update, templates.Text(ctx, templates.CHOOSE_VOICE_BUTTON), callback.New(VOICE_SET_ACTION, voiceId)),
+		},
+		{
+			b.callbackButton(update, favoriteButton, callback.New(VOICE_FAVORITE_ACTION, voiceId, page, filterData)),
+		},
+		{
+			b.callbackButton(update, templates.Text(ctx, templates.BACK_BUTTON), callback.New(VOICE_PAGE_ACTION, page, filterData)),
+		},
+	}
+
+	keyboardMarkup := callbackKeyboard(keyboard...)
+
+	b.editMessage(update, text, update.CallbackQuery.Message.MessageID)
+	b.editMessageWithKeyboard(update, keyboardMarkup, update.CallbackQuery.Message.MessageID)
+}
+
+func (b *TgBot) favoriteVoices(ctx context.Context, tgUser *model.TgUser) (map[int64]bool, error) {
+	ids, err := b.favoriteVoiceRep.VoiceIds(ctx, tgUser.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	favorites := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		favorites[id] = true
+	}
+	return favorites, nil
+}
+
+func (b *TgBot) getUserVoices(ctx context.Context, tgUser *model.TgUser) ([]*tts.Voice, error) {
+	return b.voiceCache.UserVoices(ctx, tgUser.ID, tgUser.SteosvoiceApiKey)
+}
+
+func (b *TgBot) updateUserVoices(ctx context.Context, tgUser *model.TgUser) ([]*tts.Voice, error) {
+	return b.voiceCache.Refresh(ctx, tgUser.ID, tgUser.SteosvoiceApiKey)
+}
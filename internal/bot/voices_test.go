@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Quiexx/narrator-bot/internal/templates"
+)
+
+func TestNewVoiceFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+		cut   bool
+	}{
+		{name: "short", query: " anna ", want: "anna"},
+		{name: "markdown", query: "a_b*c`d[e", want: "a_b*c`d[e"},
+		{name: "separator", query: "a:b", want: "a b"},
+		{name: "long cyrillic", query: "Александра Петровна", want: "Алексан", cut: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newVoiceFilter(test.query)
+			if f.Query != test.want || f.cut != test.cut {
+				t.Fatalf("got %q cut %v, want %q cut %v", f.Query, f.cut, test.want, test.cut)
+			}
+			if len(f.Query) > VOICE_QUERY_LENGTH || !utf8.ValidString(f.Query) {
+				t.Fatalf("query %q doesn't fit in callback data", f.Query)
+			}
+		})
+	}
+}
+
+func TestVoiceListTitleEscapesQuery(t *testing.T) {
+	ctx := templates.WithLanguage(context.Background(), templates.EN)
+
+	title := voiceListTitle(ctx, &voiceFilter{Query: "a_b*c`d[e"}, 1)
+	if !strings.Contains(title, `a\_b\*c\`+"`"+`d\[e`) {
+		t.Fatalf("query isn't escaped in %q", title)
+	}
+}
@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/metrics"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const SECRET_TOKEN_HEADER = "X-Telegram-Bot-Api-Secret-Token"
+
+// UsesWebhook reports whether updates are delivered by telegram to the webhook
+// instead of being fetched with long polling.
+func (b *TgBot) UsesWebhook() bool {
+	return b.serverUrl != ""
+}
+
+// SetWebhooks registers the webhook with the secret telegram sends back in
+// SECRET_TOKEN_HEADER. The config of the api client has no field for the
+// secret, so the parameters are built here.
+func (b *TgBot) SetWebhooks() error {
+	params := tgbotapi.Params{}
+	params["url"] = b.serverUrl + b.webhookPattern
+	params.AddNonEmpty("secret_token", b.webhookSecret)
+
+	start := time.Now()
+	_, err := b.bot.MakeRequest("setWebhook", params)
+	metrics.Since(metrics.TelegramRequestDuration.WithLabelValues("setWebhook", telegramResult(err)), start)
+	return err
+}
+
+func (b *TgBot) DeleteWebhooks() error {
+	_, err := b.request(tgbotapi.DeleteWebhookConfig{})
+	return err
+}
+
+// WebhookHandler accepts updates posted by telegram to WebhookPattern.
+func (b *TgBot) WebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !b.validSecret(r) {
+			b.logger.Warn("rejected webhook request with invalid secret", "remote_addr", r.RemoteAddr)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		update, err := b.bot.HandleUpdate(r)
+		if err != nil {
+			b.logger.Error("failed to parse webhook update", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case b.webhookUpdates <- *update:
+			w.WriteHeader(http.StatusOK)
+		case <-b.stopCh:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+}
+
+func (b *TgBot) validSecret(r *http.Request) bool {
+	if b.webhookSecret == "" {
+		return true
+	}
+
+	secret := r.Header.Get(SECRET_TOKEN_HEADER)
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(b.webhookSecret)) == 1
+}
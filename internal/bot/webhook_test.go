@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const TEST_UPDATE = `{"update_id": 42, "message": {"message_id": 1, "chat": {"id": 7, "type": "private"}, "text": "hello"}}`
+
+func newWebhookBot(secret string) *TgBot {
+	return &TgBot{
+		bot:            &tgbotapi.BotAPI{},
+		webhookSecret:  secret,
+		webhookUpdates: make(chan tgbotapi.Update, 1),
+		stopCh:         make(chan struct{}),
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		status int
+		queued bool
+	}{
+		{name: "valid update", secret: "secret", body: TEST_UPDATE, status: http.StatusOK, queued: true},
+		{name: "wrong secret", secret: "wrong", body: TEST_UPDATE, status: http.StatusForbidden},
+		{name: "missing secret", body: TEST_UPDATE, status: http.StatusForbidden},
+		{name: "malformed body", secret: "secret", body: `{"update_id":`, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newWebhookBot("secret")
+			server := httptest.NewServer(b.WebhookHandler())
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.secret != "" {
+				req.Header.Set(SECRET_TOKEN_HEADER, test.secret)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != test.status {
+				t.Fatalf("got status %v, want %v", resp.StatusCode, test.status)
+			}
+
+			select {
+			case update := <-b.webhookUpdates:
+				if !test.queued {
+					t.Fatalf("update %v is queued", update.UpdateID)
+				}
+				if update.UpdateID != 42 || update.Message.Text != "hello" {
+					t.Fatalf("got update %+v", update)
+				}
+			default:
+				if test.queued {
+					t.Fatal("update isn't queued")
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookHandlerStopped(t *testing.T) {
+	b := newWebhookBot("")
+	b.webhookUpdates = make(chan tgbotapi.Update)
+	close(b.stopCh)
+
+	rec := httptest.NewRecorder()
+	b.WebhookHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(TEST_UPDATE)))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSetWebhooks(t *testing.T) {
+	var params url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/setWebhook") {
+			r.ParseForm()
+			params = r.PostForm
+		}
+		io.WriteString(w, `{"ok": true, "result": {"id": 1, "is_bot": true, "username": "test_bot"}}`)
+	}))
+	defer server.Close()
+
+	api, err := tgbotapi.NewBotAPIWithClient("token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &TgBot{bot: api, serverUrl: "https://example.com", webhookPattern: "/update", webhookSecret: "a&b"}
+	if err := b.SetWebhooks(); err != nil {
+		t.Fatal(err)
+	}
+
+	if params.Get("url") != "https://example.com/update" || params.Get("secret_token") != "a&b" {
+		t.Fatalf("webhook is set with %v", params)
+	}
+}
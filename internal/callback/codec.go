@@ -0,0 +1,112 @@
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// VERSION changes when payloads of old keyboards can't be handled anymore.
+	VERSION = "1"
+
+	// MAX_LENGTH is the limit of telegram on callback data.
+	MAX_LENGTH = 64
+	SEPARATOR  = ":"
+	MAC_LENGTH = 8
+)
+
+var (
+	ErrMalformed = errors.New("malformed callback data")
+	ErrStale     = errors.New("stale callback data")
+	ErrForged    = errors.New("forged callback data")
+	ErrTooLong   = errors.New("callback data is too long")
+)
+
+// Codec encodes payloads as "<version><action>:<args>:<issued at>:<mac>".
+// The mac binds the payload to the user the keyboard was sent to, so other
+// users can't press it, e.g. in group chats, and nobody can craft payloads.
+type Codec struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewCodec signs payloads with a key derived from secret. Payloads older than
+// ttl are stale, zero ttl keeps them valid forever.
+func NewCodec(secret string, ttl time.Duration) *Codec {
+	key := sha256.Sum256([]byte("callback" + SEPARATOR + secret))
+	return &Codec{key: key[:], ttl: ttl}
+}
+
+func (c *Codec) Encode(userId int64, p *Payload) (string, error) {
+	for _, value := range append([]string{p.Action}, p.Args...) {
+		if strings.Contains(value, SEPARATOR) {
+			return "", fmt.Errorf("%w: %q contains %q", ErrMalformed, value, SEPARATOR)
+		}
+	}
+
+	fields := append([]string{VERSION + p.Action}, p.Args...)
+	fields = append(fields, strconv.FormatInt(time.Now().Unix(), 36))
+
+	signed := strings.Join(fields, SEPARATOR)
+	data := signed + SEPARATOR + c.mac(userId, signed)
+	if len(data) > MAX_LENGTH {
+		return "", fmt.Errorf("%w: %v bytes of %v", ErrTooLong, len(data), p.Action)
+	}
+
+	return data, nil
+}
+
+// Decode verifies that data was encoded for userId and isn't stale.
+func (c *Codec) Decode(userId int64, data string) (*Payload, error) {
+	if !strings.HasPrefix(data, VERSION) {
+		return nil, ErrStale
+	}
+
+	i := strings.LastIndex(data, SEPARATOR)
+	if i == -1 {
+		return nil, ErrMalformed
+	}
+	signed, mac := data[:i], data[i+1:]
+
+	if !hmac.Equal([]byte(mac), []byte(c.mac(userId, signed))) {
+		return nil, ErrForged
+	}
+
+	fields := strings.Split(strings.TrimPrefix(signed, VERSION), SEPARATOR)
+	if len(fields) < 2 {
+		return nil, ErrMalformed
+	}
+
+	issuedAt, err := strconv.ParseInt(fields[len(fields)-1], 36, 64)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	if c.ttl > 0 && time.Since(time.Unix(issuedAt, 0)) > c.ttl {
+		return nil, ErrStale
+	}
+
+	return &Payload{Action: fields[0], Args: fields[1 : len(fields)-1]}, nil
+}
+
+// Action returns the action of data without verifying it, e.g. to pick a
+// queue for the update.
+func Action(data string) string {
+	if !strings.HasPrefix(data, VERSION) {
+		return ""
+	}
+	action, _, _ := strings.Cut(strings.TrimPrefix(data, VERSION), SEPARATOR)
+	return action
+}
+
+func (c *Codec) mac(userId int64, signed string) string {
+	h := hmac.New(sha256.New, c.key)
+	h.Write([]byte(strconv.FormatInt(userId, 10) + SEPARATOR + signed))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:MAC_LENGTH])
+}
@@ -0,0 +1,140 @@
+package callback
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const TEST_USER_ID = 42
+
+func TestCodecRoundTrip(t *testing.T) {
+	c := NewCodec("secret", time.Hour)
+
+	data, err := c.Encode(TEST_USER_ID, New("voice.info", 12, 3, "0,1,0,anna"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := c.Decode(TEST_USER_ID, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Action != "voice.info" || p.Int(0) != 12 || p.Int(1) != 3 || p.String(2) != "0,1,0,anna" {
+		t.Fatalf("got %+v", p)
+	}
+	if Action(data) != "voice.info" {
+		t.Fatalf("got action %q", Action(data))
+	}
+}
+
+func TestCodecDecodeErrors(t *testing.T) {
+	c := NewCodec("secret", time.Hour)
+
+	valid, err := c.Encode(TEST_USER_ID, New("voice.set", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signed builds data with a valid mac, as if the key leaked
+	signed := func(signed string) string {
+		return signed + SEPARATOR + c.mac(TEST_USER_ID, signed)
+	}
+	issuedAt := func(at time.Time) string {
+		return strconv.FormatInt(at.Unix(), 36)
+	}
+
+	tests := []struct {
+		name   string
+		userId int64
+		data   string
+		want   error
+	}{
+		{name: "other user", userId: TEST_USER_ID + 1, data: valid, want: ErrForged},
+		{name: "other secret", userId: TEST_USER_ID, data: reencode(t, NewCodec("other", time.Hour)), want: ErrForged},
+		{name: "changed args", userId: TEST_USER_ID, data: strings.Replace(valid, ":1:", ":2:", 1), want: ErrForged},
+		{name: "expired", userId: TEST_USER_ID, data: signed(VERSION + "voice.set:1:" + issuedAt(time.Now().Add(-2*time.Hour))), want: ErrStale},
+		{name: "wrong version", userId: TEST_USER_ID, data: "0" + strings.TrimPrefix(valid, VERSION), want: ErrStale},
+		{name: "empty", userId: TEST_USER_ID, data: "", want: ErrStale},
+		{name: "no separator", userId: TEST_USER_ID, data: VERSION + "voice.set", want: ErrMalformed},
+		{name: "no issued at", userId: TEST_USER_ID, data: signed(VERSION + "voice.set"), want: ErrMalformed},
+		{name: "bad issued at", userId: TEST_USER_ID, data: signed(VERSION + "voice.set:1:!"), want: ErrMalformed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := c.Decode(test.userId, test.data)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func reencode(t *testing.T, c *Codec) string {
+	data, err := c.Encode(TEST_USER_ID, New("voice.set", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCodecZeroTTLNeverExpires(t *testing.T) {
+	c := NewCodec("secret", 0)
+
+	signed := VERSION + "voice.set:1:" + strconv.FormatInt(time.Now().Add(-1000*time.Hour).Unix(), 36)
+	_, err := c.Decode(TEST_USER_ID, signed+SEPARATOR+c.mac(TEST_USER_ID, signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCodecEncodeLimits(t *testing.T) {
+	c := NewCodec("secret", time.Hour)
+
+	data, err := c.Encode(TEST_USER_ID, New("a", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an argument of room bytes fills the limit exactly
+	room := MAX_LENGTH - len(data)
+
+	data, err = c.Encode(TEST_USER_ID, New("a", strings.Repeat("x", room)))
+	if err != nil || len(data) != MAX_LENGTH {
+		t.Fatalf("got %v bytes, %v", len(data), err)
+	}
+
+	_, err = c.Encode(TEST_USER_ID, New("a", strings.Repeat("x", room+1)))
+	if !errors.Is(err, ErrTooLong) {
+		t.Fatalf("got %v, want %v", err, ErrTooLong)
+	}
+
+	_, err = c.Encode(TEST_USER_ID, New("a", "x"+SEPARATOR+"y"))
+	if !errors.Is(err, ErrMalformed) {
+		t.Fatalf("got %v, want %v", err, ErrMalformed)
+	}
+}
+
+func TestDispatcherChecksKinds(t *testing.T) {
+	d := NewDispatcher()
+	d.Register("voice.set", nil, INT)
+
+	tests := []struct {
+		p    *Payload
+		want error
+	}{
+		{p: New("voice.set", 1)},
+		{p: New("voice.set", "x"), want: ErrMalformed},
+		{p: New("voice.set"), want: ErrMalformed},
+		{p: New("voice.del", 1), want: ErrUnknownAction},
+	}
+
+	for _, test := range tests {
+		_, err := d.Handler(test.p)
+		if !errors.Is(err, test.want) {
+			t.Fatalf("%+v: got %v, want %v", test.p, err, test.want)
+		}
+	}
+}
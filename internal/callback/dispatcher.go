@@ -0,0 +1,103 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var ErrUnknownAction = errors.New("unknown callback action")
+
+// Kind is the type of a payload argument.
+type Kind int
+
+const (
+	INT Kind = iota
+	STRING
+)
+
+// Payload is what a button does: the action and its arguments.
+type Payload struct {
+	Action string
+	Args   []string
+}
+
+// New formats args with fmt.Sprint, so they are ints or strings.
+func New(action string, args ...interface{}) *Payload {
+	p := &Payload{Action: action, Args: make([]string, 0, len(args))}
+	for _, arg := range args {
+		p.Args = append(p.Args, fmt.Sprint(arg))
+	}
+	return p
+}
+
+// Int returns the argument i. Handlers get payloads whose arguments match the
+// kinds they were registered with, so it's 0 only for a wrong i.
+func (p *Payload) Int(i int) int64 {
+	if i >= len(p.Args) {
+		return 0
+	}
+	value, _ := strconv.ParseInt(p.Args[i], 10, 64)
+	return value
+}
+
+func (p *Payload) String(i int) string {
+	if i >= len(p.Args) {
+		return ""
+	}
+	return p.Args[i]
+}
+
+type Handler func(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser, p *Payload)
+
+type route struct {
+	kinds   []Kind
+	handler Handler
+}
+
+// Dispatcher finds the handler of a payload by its action.
+type Dispatcher struct {
+	routes map[string]*route
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{routes: map[string]*route{}}
+}
+
+// Register handles action with payloads having arguments of kinds. It panics
+// if action is taken.
+func (d *Dispatcher) Register(action string, handler Handler, kinds ...Kind) {
+	if _, ok := d.routes[action]; ok {
+		panic(fmt.Sprintf("callback: multiple registrations for %v", action))
+	}
+	d.routes[action] = &route{kinds: kinds, handler: handler}
+}
+
+// Handler returns the handler of p if the arguments of p match its kinds.
+func (d *Dispatcher) Handler(p *Payload) (Handler, error) {
+	r, ok := d.routes[p.Action]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownAction, p.Action)
+	}
+
+	if len(p.Args) != len(r.kinds) {
+		return nil, fmt.Errorf("%w: %v wants %v arguments, got %v", ErrMalformed, p.Action, len(r.kinds), len(p.Args))
+	}
+
+	for i, kind := range r.kinds {
+		if kind != INT {
+			continue
+		}
+		_, err := strconv.ParseInt(p.Args[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: argument %v of %v is not an int", ErrMalformed, i, p.Action)
+		}
+	}
+
+	return r.handler, nil
+}
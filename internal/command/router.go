@@ -0,0 +1,148 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	ErrNotCommand  = errors.New("not a command")
+	ErrOtherBot    = errors.New("command addressed to another bot")
+	ErrUnknown     = errors.New("unknown command")
+	ErrPrivateOnly = errors.New("command works only in private chats")
+	ErrGroupOnly   = errors.New("command works only in group chats")
+)
+
+// Chats are the kinds of chats a command works in.
+type Chats int
+
+const (
+	PRIVATE_CHATS Chats = 1 << iota
+	GROUP_CHATS
+
+	ALL_CHATS = PRIVATE_CHATS | GROUP_CHATS
+)
+
+type Handler func(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser)
+
+// Middleware wraps the handler of cmd, e.g. to count or authorize calls.
+type Middleware func(cmd *Command, next Handler) Handler
+
+type Command struct {
+	// Name without the leading slash, e.g. "voice"
+	Name    string
+	Aliases []string
+	// Description is the templates key of the text shown in /help and the
+	// telegram command menu.
+	Description string
+	Chats       Chats
+	// Hidden commands are left out of /help and the menu, e.g. admin commands.
+	Hidden  bool
+	Handler Handler
+}
+
+// Router finds the command of a message by its name or alias.
+type Router struct {
+	botName    string
+	commands   []*Command
+	byName     map[string]*Command
+	middleware []Middleware
+}
+
+// NewRouter routes commands without a bot name and the ones addressed to
+// botName, e.g. /start@botName.
+func NewRouter(botName string) *Router {
+	return &Router{
+		botName: botName,
+		byName:  map[string]*Command{},
+	}
+}
+
+// Register adds commands. It panics if a name or alias is taken, like
+// http.ServeMux does with patterns.
+func (r *Router) Register(commands ...*Command) {
+	for _, cmd := range commands {
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			name = strings.ToLower(name)
+			if _, ok := r.byName[name]; ok {
+				panic(fmt.Sprintf("command: multiple registrations for /%v", name))
+			}
+			r.byName[name] = cmd
+		}
+		r.commands = append(r.commands, cmd)
+	}
+}
+
+// Use adds middleware. The first added runs first.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Visible returns commands shown in /help and the menu of chats, in the order
+// they were registered.
+func (r *Router) Visible(chats Chats) []*Command {
+	visible := []*Command{}
+	for _, cmd := range r.commands {
+		if !cmd.Hidden && cmd.Chats&chats != 0 {
+			visible = append(visible, cmd)
+		}
+	}
+	return visible
+}
+
+// Parse returns the lower case name and the arguments of the command msg
+// starts with.
+func (r *Router) Parse(msg *tgbotapi.Message) (string, string, error) {
+	if msg == nil || !msg.IsCommand() {
+		return "", "", ErrNotCommand
+	}
+
+	name, target, _ := strings.Cut(msg.CommandWithAt(), "@")
+	if target != "" && !strings.EqualFold(target, r.botName) {
+		return "", "", ErrOtherBot
+	}
+
+	return strings.ToLower(name), strings.TrimSpace(msg.CommandArguments()), nil
+}
+
+// Route returns the command msg calls if it works in the chat of msg.
+func (r *Router) Route(msg *tgbotapi.Message) (*Command, error) {
+	name, _, err := r.Parse(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, ok := r.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w /%v", ErrUnknown, name)
+	}
+
+	chats := GROUP_CHATS
+	if msg.Chat.IsPrivate() {
+		chats = PRIVATE_CHATS
+	}
+
+	switch {
+	case cmd.Chats&chats != 0:
+		return cmd, nil
+	case chats == PRIVATE_CHATS:
+		return cmd, ErrGroupOnly
+	default:
+		return cmd, ErrPrivateOnly
+	}
+}
+
+// Handler returns the handler of cmd wrapped with the middleware.
+func (r *Router) Handler(cmd *Command) Handler {
+	handler := cmd.Handler
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](cmd, handler)
+	}
+	return handler
+}
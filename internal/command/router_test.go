@@ -0,0 +1,100 @@
+package command
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func newTestMessage(text string, chatType string) *tgbotapi.Message {
+	msg := &tgbotapi.Message{Text: text, Chat: &tgbotapi.Chat{ID: 1, Type: chatType}}
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+	return msg
+}
+
+func newTestRouter() *Router {
+	r := NewRouter("NarratorBot")
+	r.Register(
+		&Command{Name: "start", Chats: ALL_CHATS},
+		&Command{Name: "voice", Aliases: []string{"voices"}, Chats: PRIVATE_CHATS},
+		&Command{Name: "symbols", Aliases: []string{"balance"}, Chats: PRIVATE_CHATS},
+		&Command{Name: "language", Aliases: []string{"lang"}, Chats: ALL_CHATS},
+		&Command{Name: "say", Chats: GROUP_CHATS},
+	)
+	return r
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text     string
+		chatType string
+		name     string
+		args     string
+		err      error
+	}{
+		{text: "/voice", chatType: "private", name: "voice"},
+		{text: "/voices anna", chatType: "private", name: "voices", args: "anna"},
+		{text: "/VOICE", chatType: "private", name: "voice"},
+		{text: "/start@NarratorBot", chatType: "group", name: "start"},
+		{text: "/start@narratorbot", chatType: "group", name: "start"},
+		{text: "/start@OtherBot", chatType: "group", err: ErrOtherBot},
+		// pasted commands of other bots in private chats aren't narrated
+		{text: "/start@OtherBot", chatType: "private", err: ErrOtherBot},
+		{text: "start", chatType: "private", err: ErrNotCommand},
+		{text: "hi /start", chatType: "private", err: ErrNotCommand},
+	}
+
+	r := newTestRouter()
+	for _, test := range tests {
+		t.Run(test.chatType+" "+test.text, func(t *testing.T) {
+			name, args, err := r.Parse(newTestMessage(test.text, test.chatType))
+			if !errors.Is(err, test.err) || name != test.name || args != test.args {
+				t.Fatalf("got %q %q %v, want %q %q %v", name, args, err, test.name, test.args, test.err)
+			}
+		})
+	}
+}
+
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		text     string
+		chatType string
+		name     string
+		err      error
+	}{
+		{text: "/voices", chatType: "private", name: "voice"},
+		{text: "/balance", chatType: "private", name: "symbols"},
+		{text: "/lang@NarratorBot", chatType: "group", name: "language"},
+		{text: "/voice", chatType: "group", name: "voice", err: ErrPrivateOnly},
+		{text: "/say", chatType: "private", name: "say", err: ErrGroupOnly},
+		{text: "/voicez", chatType: "private", err: ErrUnknown},
+	}
+
+	r := newTestRouter()
+	for _, test := range tests {
+		t.Run(test.chatType+" "+test.text, func(t *testing.T) {
+			cmd, err := r.Route(newTestMessage(test.text, test.chatType))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if test.name != "" && (cmd == nil || cmd.Name != test.name) {
+				t.Fatalf("got %+v, want /%v", cmd, test.name)
+			}
+		})
+	}
+}
+
+func TestRegisterTakenName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("taken alias is registered")
+		}
+	}()
+
+	newTestRouter().Register(&Command{Name: "Voices"})
+}
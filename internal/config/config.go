@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type Config struct {
 	PostgresHost     string `env:"BOT_POSTGRES_HOST" envDefault:"localhost"`
 	PostgresPort     string `env:"BOT_POSTGRES_PORT" envDefault:"8432"`
@@ -9,7 +11,56 @@ type Config struct {
 	PostgresSSMode   string `env:"BOT_POSTGRES_SSL_MODE" envDefault:"disable"`
 
 	BotToken       string `env:"BOT_TOKEN" envDefault:"7067695942:AAEdD8gTWgjSPrFthaS_flzhtRapTt6tfWw"`
-	SetWebhookUrl  string `env:"SET_WEBHOOK_URL" envDefault:"https://api.telegram.org/bot%v/setWebhook?url=%v%v"`
 	ServerUrl      string `env:"SERVER_URL" envDefault:""`
 	WebhookPattern string `env:"WEBHOOK_PATTERN" envDefault:"/update"`
+	WebhookSecret  string `env:"WEBHOOK_SECRET" envDefault:""`
+	MetricsPattern string `env:"METRICS_PATTERN" envDefault:"/metrics"`
+
+	// LogLevel is one of debug, info, warn, error, LogFormat is text or json
+	LogLevel           string        `env:"LOG_LEVEL" envDefault:"info"`
+	LogFormat          string        `env:"LOG_FORMAT" envDefault:"text"`
+	SlowQueryThreshold time.Duration `env:"SLOW_QUERY_THRESHOLD" envDefault:"200ms"`
+
+	HealthCheckTimeout time.Duration `env:"HEALTH_CHECK_TIMEOUT" envDefault:"5s"`
+	UpdateStallTimeout time.Duration `env:"UPDATE_STALL_TIMEOUT" envDefault:"5m"`
+
+	// SteosVoiceCallTimeout limits each attempt of a call, attempts have no
+	// deadline of their own if 0
+	SteosVoiceCallTimeout time.Duration `env:"STEOSVOICE_CALL_TIMEOUT" envDefault:"60s"`
+	SteosVoiceMaxRetries  int           `env:"STEOSVOICE_MAX_RETRIES" envDefault:"3"`
+	SteosVoiceBackoff     time.Duration `env:"STEOSVOICE_BACKOFF" envDefault:"500ms"`
+
+	ShutdownTimeout  time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+	Workers          int           `env:"WORKERS" envDefault:"8"`
+	MaxQueuedUpdates int           `env:"MAX_QUEUED_UPDATES" envDefault:"100"`
+
+	// rate limits are in updates per second, zero disables the limit
+	UserRateLimit   float64 `env:"USER_RATE_LIMIT" envDefault:"0.5"`
+	UserRateBurst   int     `env:"USER_RATE_BURST" envDefault:"5"`
+	GlobalRateLimit float64 `env:"GLOBAL_RATE_LIMIT" envDefault:"25"`
+	GlobalRateBurst int     `env:"GLOBAL_RATE_BURST" envDefault:"50"`
+
+	// AdminIds are telegram ids of users allowed to /block and /unblock
+	AdminIds []int64 `env:"ADMIN_IDS" envSeparator:","`
+
+	// StateTimeout is how long the bot waits for the next step of a flow, e.g. the api key after /apikey
+	StateTimeout time.Duration `env:"STATE_TIMEOUT" envDefault:"10m"`
+	// CallbackTTL is how long inline keyboard buttons can be pressed, forever if 0
+	CallbackTTL time.Duration `env:"CALLBACK_TTL" envDefault:"168h"`
+
+	SynthesisChunkLimit int           `env:"SYNTHESIS_CHUNK_LIMIT" envDefault:"1000"`
+	VoiceCacheTTL       time.Duration `env:"VOICE_CACHE_TTL" envDefault:"1h"`
+
+	AudioMaxSize       int64         `env:"AUDIO_MAX_SIZE" envDefault:"20971520"`
+	AudioFetchTimeout  time.Duration `env:"AUDIO_FETCH_TIMEOUT" envDefault:"30s"`
+	AudioEncoder       string        `env:"AUDIO_ENCODER" envDefault:"ffmpeg"`
+	AudioEncodeTimeout time.Duration `env:"AUDIO_ENCODE_TIMEOUT" envDefault:"30s"`
+	FFmpegPath         string        `env:"FFMPEG_PATH" envDefault:"ffmpeg"`
+
+	InlineChatId   int64         `env:"INLINE_CHAT_ID" envDefault:"0"`
+	InlineDebounce time.Duration `env:"INLINE_DEBOUNCE" envDefault:"1s"`
+
+	// EncryptionKeys are base64 encoded AES keys by key id, e.g. "k1:<key>,k2:<key>"
+	EncryptionKeys  map[string]string `env:"ENCRYPTION_KEYS" envKeyValSeparator:":"`
+	EncryptionKeyId string            `env:"ENCRYPTION_KEY_ID" envDefault:""`
 }
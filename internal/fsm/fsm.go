@@ -0,0 +1,137 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	ErrUnknownState      = errors.New("unknown state")
+	ErrInvalidTransition = errors.New("invalid transition")
+)
+
+type Handler func(ctx context.Context, update *tgbotapi.Update, tgUser *model.TgUser)
+
+// State is a step of a conversational flow.
+type State struct {
+	Name string
+	// Handler gets the updates of users in the state, nil for the initial state.
+	Handler Handler
+	// Timeout after which the flow is abandoned, never if zero.
+	Timeout time.Duration
+	// Next are states the flow moves to from this one. The initial state can
+	// be returned to from any state.
+	Next []string
+}
+
+// Machine keeps the state of each user in TgUser, so flows survive restarts.
+type Machine struct {
+	initial *State
+	states  map[string]*State
+}
+
+func New(initial *State, states ...*State) *Machine {
+	m := &Machine{
+		initial: initial,
+		states:  map[string]*State{initial.Name: initial},
+	}
+
+	for _, state := range states {
+		m.states[state.Name] = state
+	}
+
+	return m
+}
+
+// Current returns the state of tgUser. Users in states that are not declared
+// anymore are in the initial state.
+func (m *Machine) Current(tgUser *model.TgUser) *State {
+	state, ok := m.states[tgUser.State]
+	if !ok {
+		return m.initial
+	}
+	return state
+}
+
+// Expired reports whether tgUser left a flow unfinished for longer than the
+// timeout of its state.
+func (m *Machine) Expired(tgUser *model.TgUser, now time.Time) bool {
+	if tgUser.State == m.initial.Name {
+		return false
+	}
+
+	state, ok := m.states[tgUser.State]
+	if !ok {
+		return true
+	}
+
+	return state.Timeout > 0 && now.Sub(tgUser.StateChangedAt) > state.Timeout
+}
+
+// Transition moves tgUser to the state named to, storing payload as JSON for
+// the handler of that state. The caller saves tgUser.
+func (m *Machine) Transition(tgUser *model.TgUser, to string, payload interface{}) error {
+	if _, ok := m.states[to]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownState, to)
+	}
+
+	if to != m.initial.Name && !contains(m.Current(tgUser).Next, to) {
+		return fmt.Errorf("%w from %q to %q", ErrInvalidTransition, tgUser.State, to)
+	}
+
+	data := ""
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("encode state payload: %w", err)
+		}
+		data = string(encoded)
+	}
+
+	tgUser.State = to
+	tgUser.StatePayload = data
+	tgUser.StateChangedAt = time.Now()
+	return nil
+}
+
+// Reset moves tgUser to the initial state. It reports false if tgUser was
+// already there.
+func (m *Machine) Reset(tgUser *model.TgUser) bool {
+	if tgUser.State == m.initial.Name && tgUser.StatePayload == "" {
+		return false
+	}
+
+	tgUser.State = m.initial.Name
+	tgUser.StatePayload = ""
+	tgUser.StateChangedAt = time.Now()
+	return true
+}
+
+// Payload decodes the payload stored by the last transition of tgUser into v.
+func Payload(tgUser *model.TgUser, v interface{}) error {
+	if tgUser.StatePayload == "" {
+		return nil
+	}
+
+	err := json.Unmarshal([]byte(tgUser.StatePayload), v)
+	if err != nil {
+		return fmt.Errorf("decode state payload: %w", err)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	STATUS_OK   = "ok"
+	STATUS_FAIL = "fail"
+)
+
+// Check returns details worth showing in the report, e.g. timestamps, and
+// an error if the dependency isn't usable.
+type Check func(ctx context.Context) (map[string]interface{}, error)
+
+type CheckResult struct {
+	Status  string                 `json:"status"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status string                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks,omitempty"`
+}
+
+// Liveness reports that the process is up and serves http.
+func Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, http.StatusOK, &Report{Status: STATUS_OK})
+	})
+}
+
+// Readiness runs all checks concurrently, each limited by timeout, and
+// responds with 503 if any of them fails.
+func Readiness(timeout time.Duration, checks map[string]Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		report := run(ctx, checks)
+
+		status := http.StatusOK
+		if report.Status != STATUS_OK {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, status, report)
+	})
+}
+
+func run(ctx context.Context, checks map[string]Check) *Report {
+	report := &Report{Status: STATUS_OK, Checks: map[string]*CheckResult{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := runCheck(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[name] = result
+			if result.Status != STATUS_OK {
+				report.Status = STATUS_FAIL
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+	return report
+}
+
+// runCheck gives up on checks ignoring ctx when it's done.
+func runCheck(ctx context.Context, check Check) *CheckResult {
+	done := make(chan *CheckResult, 1)
+	go func() {
+		details, err := check(ctx)
+		result := &CheckResult{Status: STATUS_OK, Details: details}
+		if err != nil {
+			result.Status = STATUS_FAIL
+			result.Error = err.Error()
+		}
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return &CheckResult{Status: STATUS_FAIL, Error: ctx.Err().Error()}
+	}
+}
+
+func writeReport(w http.ResponseWriter, status int, report *Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(report)
+	if err != nil {
+		slog.Error("failed to write health report", "error", err)
+	}
+}
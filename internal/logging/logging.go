@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	TEXT_FORMAT = "text"
+	JSON_FORMAT = "json"
+
+	REDACTED = "[REDACTED]"
+)
+
+// sensitiveKeys are attribute keys whose values are never logged.
+var sensitiveKeys = map[string]bool{
+	"api_key":       true,
+	"apikey":        true,
+	"authorization": true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+}
+
+type ctxKey struct{}
+
+// New builds a logger writing records of at least level in format to w.
+// Values of sensitive attributes are dropped, and secrets, e.g. the bot
+// token telegram api errors contain in urls, are cut out of all strings and
+// errors.
+func New(level string, format string, w io.Writer, secrets ...string) (*slog.Logger, error) {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("parse log level: %w", err)
+	}
+
+	replacements := []string{}
+	for _, secret := range secrets {
+		if secret != "" {
+			replacements = append(replacements, secret, REDACTED)
+		}
+	}
+	replacer := strings.NewReplacer(replacements...)
+
+	opts := &slog.HandlerOptions{
+		Level: lvl,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			return redact(attr, replacer)
+		},
+	}
+
+	switch format {
+	case TEXT_FORMAT, "":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case JSON_FORMAT:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+func redact(attr slog.Attr, replacer *strings.Replacer) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, REDACTED)
+	}
+
+	value := attr.Value.Resolve()
+	switch {
+	case value.Kind() == slog.KindString:
+		return slog.String(attr.Key, replacer.Replace(value.String()))
+	case value.Kind() == slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, replacer.Replace(err.Error()))
+		}
+	}
+
+	return attr
+}
+
+// WithLogger attaches logger to ctx, e.g. with fields of the update being handled.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger attached to ctx or fallback.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	logger, ok := ctx.Value(ctxKey{}).(*slog.Logger)
+	if !ok {
+		return fallback
+	}
+	return logger
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/tts"
+	"github.com/Quiexx/narrator-bot/internal/workerpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const NAMESPACE = "narrator"
+
+var (
+	Updates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "updates_total",
+		Help:      "Telegram updates received by type.",
+	}, []string{"type"})
+
+	Commands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "commands_total",
+		Help:      "Bot commands received.",
+	}, []string{"command"})
+
+	Syntheses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "syntheses_total",
+		Help:      "Speech synthesis requests by provider and result.",
+	}, []string{"provider", "result"})
+
+	SynthesizedCharacters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "synthesized_characters_total",
+		Help:      "Characters of successfully synthesized text.",
+	}, []string{"provider"})
+
+	ProviderRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Duration of tts provider http requests, every retry is observed.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 60},
+	}, []string{"provider", "endpoint", "result"})
+
+	QueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "queue_wait_seconds",
+		Help:      "Time updates spent in the queue before a worker took them.",
+		Buckets:   []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	})
+
+	TelegramRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "telegram_request_duration_seconds",
+		Help:      "Duration of telegram bot api requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"request", "result"})
+)
+
+// Since observes the time passed since start in seconds.
+func Since(observer prometheus.Observer, start time.Time) {
+	observer.Observe(time.Since(start).Seconds())
+}
+
+// Result labels the outcome of a call by the class of its error.
+func Result(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, tts.ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, tts.ErrNotEnoughSymbols):
+		return "not_enough_symbols"
+	case errors.Is(err, tts.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, tts.ErrTransport):
+		return "transport"
+	case errors.Is(err, tts.ErrRejected):
+		return "rejected"
+	case errors.Is(err, tts.ErrInvalidParams):
+		return "invalid_params"
+	default:
+		return "error"
+	}
+}
+
+// RegisterCache exposes hits and misses of a cache, their ratio is
+// hits / (hits + misses).
+func RegisterCache(name string, stats func() (int64, int64)) {
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   NAMESPACE,
+		Name:        "cache_hits_total",
+		Help:        "Cache hits.",
+		ConstLabels: prometheus.Labels{"cache": name},
+	}, func() float64 {
+		hits, _ := stats()
+		return float64(hits)
+	})
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   NAMESPACE,
+		Name:        "cache_misses_total",
+		Help:        "Cache misses.",
+		ConstLabels: prometheus.Labels{"cache": name},
+	}, func() float64 {
+		_, misses := stats()
+		return float64(misses)
+	})
+}
+
+// RegisterPool exposes the state of the update queue.
+func RegisterPool(stats func() workerpool.Stats) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "queued_updates",
+		Help:      "Updates waiting for a worker.",
+	}, func() float64 {
+		return float64(stats().Queued)
+	})
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "processed_updates_total",
+		Help:      "Updates handled by workers.",
+	}, func() float64 {
+		return float64(stats().Processed)
+	})
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "rejected_updates_total",
+		Help:      "Updates rejected because the queue was full.",
+	}, func() float64 {
+		return float64(stats().Rejected)
+	})
+}
+
+// ObserveQueueWait observes the time an update spent in the queue.
+func ObserveQueueWait(wait time.Duration) {
+	QueueWait.Observe(wait.Seconds())
+}
@@ -0,0 +1,12 @@
+package model
+
+import "gorm.io/gorm"
+
+type AudioCacheEntry struct {
+	gorm.Model
+	Key     string `gorm:"uniqueIndex"`
+	VoiceId int64
+	Format  string
+	FileId  string
+	Hits    int64
+}
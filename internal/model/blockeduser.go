@@ -0,0 +1,11 @@
+package model
+
+import "gorm.io/gorm"
+
+// BlockedUser is a telegram user the bot ignores, blocked by an admin.
+type BlockedUser struct {
+	gorm.Model
+	TgId      int64 `gorm:"uniqueIndex"`
+	Reason    string
+	BlockedBy int64
+}
@@ -0,0 +1,12 @@
+package model
+
+import "gorm.io/gorm"
+
+// ChatSettings define how the bot narrates in a group chat. Messages are
+// narrated with the api key of the owner, chosen by a chat administrator.
+type ChatSettings struct {
+	gorm.Model
+	ChatId  int64 `gorm:"uniqueIndex"`
+	OwnerID uint
+	VoiceId int64
+}
@@ -0,0 +1,10 @@
+package model
+
+import "gorm.io/gorm"
+
+// FavoriteVoice is a voice the user starred in the voice list.
+type FavoriteVoice struct {
+	gorm.Model
+	TgUserID uint  `gorm:"uniqueIndex:idx_favorite_voice"`
+	VoiceId  int64 `gorm:"uniqueIndex:idx_favorite_voice"`
+}
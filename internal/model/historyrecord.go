@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/gorm"
+
+type HistoryRecord struct {
+	gorm.Model
+	TgUserID  uint `gorm:"index"`
+	TextHash  string
+	Preview   string
+	VoiceId   int64
+	CharCount int
+	AudioUrl  string
+	FileId    string
+	Format    string
+}
@@ -1,6 +1,10 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type TgUser struct {
 	gorm.Model
@@ -8,4 +12,12 @@ type TgUser struct {
 	SteosvoiceApiKey string
 	VoiceId          int64
 	State            string
+	StatePayload     string
+	StateChangedAt   time.Time
+	AudioFormat      string
+	SpeechSpeed      float64
+	SpeechPitch      float64
+	SpeechVolume     float64
+	SpeechEmotion    string
+	Language         string
 }
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const SWEEP_INTERVAL = time.Minute
+
+// Verdict tells whether an update may be handled. Notify is set for the
+// first rejected update since the user was last allowed, so the user is told
+// about throttling once instead of on every update.
+type Verdict struct {
+	Allowed bool
+	Global  bool
+	Notify  bool
+}
+
+type userLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	notified bool
+}
+
+// Limiter is a token bucket per user in front of a token bucket shared by
+// all users.
+type Limiter struct {
+	userRate  rate.Limit
+	userBurst int
+	global    *rate.Limiter
+
+	mu        sync.Mutex
+	users     map[int64]*userLimiter
+	lastSweep time.Time
+}
+
+// New allows each user userRate updates per second with bursts of userBurst,
+// and all users together globalRate updates per second with bursts of
+// globalBurst. A zero rate disables the limit.
+func New(userRate float64, userBurst int, globalRate float64, globalBurst int) *Limiter {
+	return &Limiter{
+		userRate:  limit(userRate),
+		userBurst: userBurst,
+		global:    rate.NewLimiter(limit(globalRate), globalBurst),
+		users:     map[int64]*userLimiter{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *Limiter) Allow(userId int64) Verdict {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	user, ok := l.users[userId]
+	if !ok {
+		user = &userLimiter{limiter: rate.NewLimiter(l.userRate, l.userBurst)}
+		l.users[userId] = user
+	}
+	user.lastSeen = now
+
+	verdict := Verdict{Allowed: true}
+	switch {
+	case !user.limiter.AllowN(now, 1):
+		verdict = Verdict{}
+	case !l.global.AllowN(now, 1):
+		verdict = Verdict{Global: true}
+	}
+
+	if verdict.Allowed {
+		user.notified = false
+		return verdict
+	}
+
+	verdict.Notify = !user.notified
+	user.notified = true
+	return verdict
+}
+
+// sweep forgets users whose buckets are full again.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < SWEEP_INTERVAL {
+		return
+	}
+	l.lastSweep = now
+
+	for id, user := range l.users {
+		if user.limiter.TokensAt(now) >= float64(l.userBurst) {
+			delete(l.users, id)
+		}
+	}
+}
+
+func limit(perSecond float64) rate.Limit {
+	if perSecond <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(perSecond)
+}
@@ -0,0 +1,39 @@
+package ratelimit
+
+import "testing"
+
+func TestUserLimit(t *testing.T) {
+	l := New(0.001, 2, 0, 0)
+
+	for i := 0; i < 2; i++ {
+		if !l.Allow(1).Allowed {
+			t.Fatalf("update %v within the burst is throttled", i)
+		}
+	}
+
+	verdict := l.Allow(1)
+	if verdict.Allowed || verdict.Global || !verdict.Notify {
+		t.Fatalf("got %+v after the burst", verdict)
+	}
+
+	verdict = l.Allow(1)
+	if verdict.Allowed || verdict.Notify {
+		t.Fatalf("got %+v, the user is notified twice", verdict)
+	}
+
+	if !l.Allow(2).Allowed {
+		t.Fatal("other user is throttled")
+	}
+}
+
+func TestGlobalLimit(t *testing.T) {
+	l := New(0, 0, 0.001, 2)
+
+	l.Allow(1)
+	l.Allow(2)
+
+	verdict := l.Allow(3)
+	if verdict.Allowed || !verdict.Global || !verdict.Notify {
+		t.Fatalf("got %+v after the global burst", verdict)
+	}
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AudioCacheRepository struct {
+	db *gorm.DB
+}
+
+func NewAudioCacheRepository(db *gorm.DB) *AudioCacheRepository {
+	return &AudioCacheRepository{db: db}
+}
+
+// Find returns nil if there is no entry with the key.
+func (r *AudioCacheRepository) Find(ctx context.Context, key string) (*model.AudioCacheEntry, error) {
+	entry := &model.AudioCacheEntry{}
+	result := r.db.WithContext(ctx).First(entry, "key = ?", key)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, wrap(result.Error, "find audio cache entry")
+	}
+
+	return entry, nil
+}
+
+// Save creates the entry or replaces the file id of the entry with the same key.
+func (r *AudioCacheRepository) Save(ctx context.Context, entry *model.AudioCacheEntry) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"file_id", "updated_at", "deleted_at"}),
+	}).Create(entry)
+	return wrap(result.Error, "save audio cache entry")
+}
+
+func (r *AudioCacheRepository) IncrementHits(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&model.AudioCacheEntry{}).
+		Where("id = ?", id).
+		UpdateColumn("hits", gorm.Expr("hits + 1"))
+	return wrap(result.Error, "increment audio cache hits of %v", id)
+}
+
+func (r *AudioCacheRepository) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Delete(&model.AudioCacheEntry{}, id)
+	return wrap(result.Error, "delete audio cache entry %v", id)
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BlocklistRepository struct {
+	db *gorm.DB
+}
+
+func NewBlocklistRepository(db *gorm.DB) *BlocklistRepository {
+	return &BlocklistRepository{db: db}
+}
+
+func (r *BlocklistRepository) BlockedIds(ctx context.Context) ([]int64, error) {
+	var tgIds []int64
+	result := r.db.WithContext(ctx).Model(&model.BlockedUser{}).Pluck("tg_id", &tgIds)
+	if result.Error != nil {
+		return nil, wrap(result.Error, "list blocked users")
+	}
+
+	return tgIds, nil
+}
+
+func (r *BlocklistRepository) Block(ctx context.Context, user *model.BlockedUser) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tg_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "blocked_by", "updated_at", "deleted_at"}),
+	}).Create(user)
+	return wrap(result.Error, "block user %v", user.TgId)
+}
+
+func (r *BlocklistRepository) Unblock(ctx context.Context, tgId int64) error {
+	result := r.db.WithContext(ctx).Unscoped().Where("tg_id = ?", tgId).Delete(&model.BlockedUser{})
+	return wrap(result.Error, "unblock user %v", tgId)
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ChatSettingsRepository struct {
+	db *gorm.DB
+}
+
+func NewChatSettingsRepository(db *gorm.DB) *ChatSettingsRepository {
+	return &ChatSettingsRepository{db: db}
+}
+
+// Get returns nil if the chat has no settings.
+func (r *ChatSettingsRepository) Get(ctx context.Context, chatId int64) (*model.ChatSettings, error) {
+	settings := &model.ChatSettings{}
+	result := r.db.WithContext(ctx).First(settings, "chat_id = ?", chatId)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, wrap(result.Error, "get settings of chat %v", chatId)
+	}
+
+	return settings, nil
+}
+
+func (r *ChatSettingsRepository) Save(ctx context.Context, settings *model.ChatSettings) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chat_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"owner_id", "voice_id", "updated_at", "deleted_at"}),
+	}).Create(settings)
+	return wrap(result.Error, "save settings of chat %v", settings.ChatId)
+}
+
+func (r *ChatSettingsRepository) Delete(ctx context.Context, chatId int64) error {
+	result := r.db.WithContext(ctx).Unscoped().Where("chat_id = ?", chatId).Delete(&model.ChatSettings{})
+	return wrap(result.Error, "delete settings of chat %v", chatId)
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FavoriteVoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewFavoriteVoiceRepository(db *gorm.DB) *FavoriteVoiceRepository {
+	return &FavoriteVoiceRepository{db: db}
+}
+
+// VoiceIds returns the ids of the user's favorite voices.
+func (r *FavoriteVoiceRepository) VoiceIds(ctx context.Context, tgUserId uint) ([]int64, error) {
+	ids := []int64{}
+	result := r.db.WithContext(ctx).Model(&model.FavoriteVoice{}).Where("tg_user_id = ?", tgUserId).Pluck("voice_id", &ids)
+	if result.Error != nil {
+		return nil, wrap(result.Error, "get favorite voices of user %v", tgUserId)
+	}
+
+	return ids, nil
+}
+
+func (r *FavoriteVoiceRepository) Add(ctx context.Context, tgUserId uint, voiceId int64) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&model.FavoriteVoice{
+		TgUserID: tgUserId,
+		VoiceId:  voiceId,
+	})
+	return wrap(result.Error, "add favorite voice %v of user %v", voiceId, tgUserId)
+}
+
+func (r *FavoriteVoiceRepository) Remove(ctx context.Context, tgUserId uint, voiceId int64) error {
+	result := r.db.WithContext(ctx).Unscoped().Where("tg_user_id = ? AND voice_id = ?", tgUserId, voiceId).Delete(&model.FavoriteVoice{})
+	return wrap(result.Error, "remove favorite voice %v of user %v", voiceId, tgUserId)
+}
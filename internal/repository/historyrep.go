@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"github.com/Quiexx/narrator-bot/internal/model"
+	"gorm.io/gorm"
+)
+
+type HistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewHistoryRepository(db *gorm.DB) *HistoryRepository {
+	return &HistoryRepository{db: db}
+}
+
+func (r *HistoryRepository) Create(ctx context.Context, record *model.HistoryRecord) error {
+	result := r.db.WithContext(ctx).Create(record)
+	return wrap(result.Error, "create history record")
+}
+
+// GetPage returns the user's records starting from the latest one and the
+// total number of the user's records.
+func (r *HistoryRepository) GetPage(ctx context.Context, tgUserId uint, offset int, limit int) ([]*model.HistoryRecord, int64, error) {
+	var count int64
+	result := r.db.WithContext(ctx).Model(&model.HistoryRecord{}).Where("tg_user_id = ?", tgUserId).Count(&count)
+	if result.Error != nil {
+		return nil, 0, wrap(result.Error, "count history of user %v", tgUserId)
+	}
+
+	records := []*model.HistoryRecord{}
+	result = r.db.WithContext(ctx).
+		Where("tg_user_id = ?", tgUserId).
+		Order("created_at desc").
+		Offset(offset).
+		Limit(limit).
+		Find(&records)
+	if result.Error != nil {
+		return nil, 0, wrap(result.Error, "get history of user %v", tgUserId)
+	}
+
+	return records, count, nil
+}
+
+func (r *HistoryRepository) Get(ctx context.Context, tgUserId uint, id uint) (*model.HistoryRecord, error) {
+	record := &model.HistoryRecord{}
+	result := r.db.WithContext(ctx).First(record, "id = ? AND tg_user_id = ?", id, tgUserId)
+	if result.Error != nil {
+		return nil, wrap(result.Error, "get history record %v", id)
+	}
+
+	return record, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/logging"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger writes gorm logs with the logger of the update in progress.
+// Query parameters are never logged, they may hold api keys.
+type GormLogger struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+}
+
+var _ gormlogger.Interface = (*GormLogger)(nil)
+var _ gorm.ParamsFilter = (*GormLogger)(nil)
+
+// NewGormLogger logs failed queries as errors, queries slower than
+// slowThreshold as warnings and other queries at debug level.
+func NewGormLogger(logger *slog.Logger, slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{logger: logger, slowThreshold: slowThreshold}
+}
+
+// LogMode is ignored, the level is set by the slog handler.
+func (l *GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx).Info(fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx).Warn(fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx).Error(fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	logger := l.log(ctx)
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		logger.Error("query failed", "sql", sql, "rows", rows, "elapsed", elapsed, "error", err)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold:
+		sql, rows := fc()
+		logger.Warn("slow query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	case logger.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		logger.Debug("query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	}
+}
+
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+func (l *GormLogger) log(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, l.logger)
+}
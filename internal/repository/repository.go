@@ -1,10 +1,20 @@
 package repository
 
 import (
+	"fmt"
+
 	"github.com/Quiexx/narrator-bot/internal/model"
 	"gorm.io/gorm"
 )
 
 func MigrateModels(db *gorm.DB) error {
-	return db.AutoMigrate(&model.TgUser{})
+	return db.AutoMigrate(&model.TgUser{}, &model.HistoryRecord{}, &model.AudioCacheEntry{}, &model.ChatSettings{}, &model.BlockedUser{}, &model.FavoriteVoice{})
+}
+
+// wrap adds the failed operation to err, nil stays nil.
+func wrap(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf(format+": %w", append(args, err)...)
 }
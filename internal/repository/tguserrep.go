@@ -1,43 +1,165 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/Quiexx/narrator-bot/internal/model"
+	"github.com/Quiexx/narrator-bot/internal/secret"
 	"gorm.io/gorm"
 )
 
+const REENCRYPT_BATCH_SIZE = 100
+
 type TgUserRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	keyring *secret.Keyring
 }
 
-func NewTgUserRepository(db *gorm.DB) *TgUserRepository {
-	return &TgUserRepository{db: db}
+// NewTgUserRepository stores api keys encrypted with keyring.
+// If keyring is nil api keys are stored as plaintext.
+func NewTgUserRepository(db *gorm.DB, keyring *secret.Keyring) *TgUserRepository {
+	return &TgUserRepository{db: db, keyring: keyring}
 }
 
-func (r *TgUserRepository) GetOrCreate(tgId int64, defaultState string) (*model.TgUser, error) {
+func (r *TgUserRepository) GetOrCreate(ctx context.Context, tgId int64, defaultState string) (*model.TgUser, error) {
 	tgUser := &model.TgUser{}
-	result := r.db.First(tgUser, "tg_id = ?", tgId)
+	result := r.db.WithContext(ctx).First(tgUser, "tg_id = ?", tgId)
 
 	if result.Error == nil {
+		err := r.decryptApiKey(tgUser)
+		if err != nil {
+			return nil, wrap(err, "decrypt api key of user %v", tgId)
+		}
 		return tgUser, nil
 	}
 
 	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-		return nil, result.Error
+		return nil, wrap(result.Error, "get user %v", tgId)
 	}
 
 	tgUser.TgId = tgId
 	tgUser.VoiceId = -1
 	tgUser.State = defaultState
-	result = r.db.Create(tgUser)
+	result = r.db.WithContext(ctx).Create(tgUser)
 
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, wrap(result.Error, "create user %v", tgId)
+	}
+
+	return tgUser, nil
+}
+
+func (r *TgUserRepository) GetById(ctx context.Context, id uint) (*model.TgUser, error) {
+	tgUser := &model.TgUser{}
+	result := r.db.WithContext(ctx).First(tgUser, id)
+	if result.Error != nil {
+		return nil, wrap(result.Error, "get user by id %v", id)
+	}
+
+	err := r.decryptApiKey(tgUser)
+	if err != nil {
+		return nil, wrap(err, "decrypt api key of user by id %v", id)
 	}
 
 	return tgUser, nil
 }
 
-func (r *TgUserRepository) UpdateUser(tgUser *model.TgUser) error {
-	result := r.db.Save(tgUser)
-	return result.Error
+func (r *TgUserRepository) UpdateUser(ctx context.Context, tgUser *model.TgUser) error {
+	// save a copy so the caller keeps working with the plaintext key
+	stored := *tgUser
+
+	apiKey, err := r.encrypt(tgUser)
+	if err != nil {
+		return wrap(err, "encrypt api key of user %v", tgUser.TgId)
+	}
+	stored.SteosvoiceApiKey = apiKey
+
+	result := r.db.WithContext(ctx).Save(&stored)
+	if result.Error != nil {
+		return wrap(result.Error, "save user %v", tgUser.TgId)
+	}
+
+	tgUser.Model = stored.Model
+	return nil
+}
+
+// ReencryptApiKeys seals plaintext api keys and api keys sealed with old
+// master keys with the primary key. It returns the number of updated users.
+func (r *TgUserRepository) ReencryptApiKeys(ctx context.Context) (int, error) {
+	if r.keyring == nil {
+		return 0, errors.New("encryption keys are not configured")
+	}
+
+	updated := 0
+	users := []*model.TgUser{}
+
+	result := r.db.WithContext(ctx).Where("steosvoice_api_key <> ''").FindInBatches(&users, REENCRYPT_BATCH_SIZE, func(tx *gorm.DB, batch int) error {
+		for _, tgUser := range users {
+			if !r.keyring.NeedsRotation(tgUser.SteosvoiceApiKey) {
+				continue
+			}
+
+			apiKey, err := r.keyring.Decrypt(tgUser.SteosvoiceApiKey, apiKeyOwner(tgUser))
+			if err != nil {
+				return err
+			}
+
+			apiKey, err = r.keyring.Encrypt(apiKey, apiKeyOwner(tgUser))
+			if err != nil {
+				return err
+			}
+
+			err = tx.Model(tgUser).UpdateColumn("steosvoice_api_key", apiKey).Error
+			if err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+
+	return updated, wrap(result.Error, "re-encrypt api keys")
+}
+
+// HasEncryptedApiKeys reports whether api keys were stored encrypted, so
+// they can't be used without the keyring.
+func (r *TgUserRepository) HasEncryptedApiKeys(ctx context.Context) (bool, error) {
+	var count int64
+	result := r.db.WithContext(ctx).Model(&model.TgUser{}).
+		Where("steosvoice_api_key LIKE ?", secret.PREFIX+"%").
+		Count(&count)
+	if result.Error != nil {
+		return false, wrap(result.Error, "count encrypted api keys")
+	}
+
+	return count > 0, nil
+}
+
+func (r *TgUserRepository) encrypt(tgUser *model.TgUser) (string, error) {
+	if r.keyring == nil || tgUser.SteosvoiceApiKey == "" {
+		return tgUser.SteosvoiceApiKey, nil
+	}
+	return r.keyring.Encrypt(tgUser.SteosvoiceApiKey, apiKeyOwner(tgUser))
+}
+
+func (r *TgUserRepository) decryptApiKey(tgUser *model.TgUser) error {
+	if r.keyring == nil || tgUser.SteosvoiceApiKey == "" {
+		return nil
+	}
+
+	apiKey, err := r.keyring.Decrypt(tgUser.SteosvoiceApiKey, apiKeyOwner(tgUser))
+	if err != nil {
+		return err
+	}
+
+	tgUser.SteosvoiceApiKey = apiKey
+	return nil
+}
+
+// apiKeyOwner binds encrypted api keys to their users, so a key copied to
+// another row fails to decrypt.
+func apiKeyOwner(tgUser *model.TgUser) string {
+	return fmt.Sprintf("tg_id:%v", tgUser.TgId)
 }
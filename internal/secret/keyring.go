@@ -0,0 +1,178 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PREFIX marks encrypted values, anything else is treated as legacy plaintext.
+// The full format is "enc:v1:<key id>:<wrapped data key>:<payload>".
+const (
+	PREFIX        = "enc:v1:"
+	DATA_KEY_SIZE = 32
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown encryption key")
+	ErrMalformed  = errors.New("malformed encrypted value")
+)
+
+// Keyring does envelope encryption: every value is sealed with a fresh data
+// key, and the data key is sealed with the primary master key. Values sealed
+// with older master keys can be decrypted as long as those keys stay in the
+// keyring.
+type Keyring struct {
+	primaryId string
+	keys      map[string]cipher.AEAD
+}
+
+// NewKeyring accepts base64 encoded AES-128/192/256 master keys by key id.
+func NewKeyring(primaryId string, encodedKeys map[string]string) (*Keyring, error) {
+	keys := map[string]cipher.AEAD{}
+
+	for id, encoded := range encodedKeys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid encryption key id %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode encryption key %q: %w", id, err)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %w", id, err)
+		}
+
+		keys[id] = aead
+	}
+
+	if _, ok := keys[primaryId]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, primaryId)
+	}
+
+	return &Keyring{primaryId: primaryId, keys: keys}, nil
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, PREFIX)
+}
+
+// Encrypt binds the value to associatedData, e.g. the id of its owner, so it
+// can't be decrypted as a value of someone else.
+func (k *Keyring) Encrypt(plaintext string, associatedData string) (string, error) {
+	dataKey := make([]byte, DATA_KEY_SIZE)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	dataAead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := seal(dataAead, []byte(plaintext), []byte(associatedData))
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(k.keys[k.primaryId], dataKey, []byte(k.primaryId))
+	if err != nil {
+		return "", err
+	}
+
+	return PREFIX + strings.Join([]string{
+		k.primaryId,
+		base64.RawStdEncoding.EncodeToString(wrappedKey),
+		base64.RawStdEncoding.EncodeToString(payload),
+	}, ":"), nil
+}
+
+// Decrypt returns legacy plaintext values as is. associatedData must be the
+// one the value was encrypted with.
+func (k *Keyring) Decrypt(value string, associatedData string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, PREFIX), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+
+	keyId := parts[0]
+	masterAead, ok := k.keys[keyId]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, keyId)
+	}
+
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	payload, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dataKey, err := open(masterAead, wrappedKey, []byte(keyId))
+	if err != nil {
+		return "", err
+	}
+
+	dataAead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataAead, payload, []byte(associatedData))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is plaintext or sealed with a master
+// key other than the primary one.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if !strings.HasPrefix(value, PREFIX) {
+		return true
+	}
+
+	return !strings.HasPrefix(value, PREFIX+k.primaryId+":")
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, data []byte, additionalData []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
@@ -0,0 +1,126 @@
+package secret
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func newTestKeyring(t *testing.T, primaryId string) *Keyring {
+	k, err := NewKeyring(primaryId, map[string]string{"a": testKey('a'), "b": testKey('b')})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	k := newTestKeyring(t, "a")
+
+	value, err := k.Encrypt("api key", "user 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(value) || strings.Contains(value, "api key") {
+		t.Fatalf("value isn't encrypted: %q", value)
+	}
+
+	plaintext, err := k.Decrypt(value, "user 1")
+	if err != nil || plaintext != "api key" {
+		t.Fatalf("got %q, %v", plaintext, err)
+	}
+
+	plaintext, err = k.Decrypt("legacy key", "user 1")
+	if err != nil || plaintext != "legacy key" {
+		t.Fatalf("plaintext isn't returned as is: %q, %v", plaintext, err)
+	}
+}
+
+func TestKeyringDecryptErrors(t *testing.T) {
+	k := newTestKeyring(t, "a")
+
+	value, err := k.Encrypt("api key", "user 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, PREFIX), ":")
+
+	tests := []struct {
+		name           string
+		value          string
+		associatedData string
+		want           error
+	}{
+		{name: "key of another user", value: value, associatedData: "user 2"},
+		{name: "unknown key id", value: PREFIX + "c:" + parts[1] + ":" + parts[2], associatedData: "user 1", want: ErrUnknownKey},
+		{name: "wrapped with another key", value: PREFIX + "b:" + parts[1] + ":" + parts[2], associatedData: "user 1"},
+		{name: "missing part", value: PREFIX + "a:" + parts[1], associatedData: "user 1", want: ErrMalformed},
+		{name: "bad base64", value: PREFIX + "a:!!:" + parts[2], associatedData: "user 1", want: ErrMalformed},
+		{name: "short payload", value: PREFIX + "a:" + parts[1] + ":AAAA", associatedData: "user 1", want: ErrMalformed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := k.Decrypt(test.value, test.associatedData)
+			if err == nil {
+				t.Fatal("value is decrypted")
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestKeyringNeedsRotation(t *testing.T) {
+	old := newTestKeyring(t, "a")
+	value, err := old.Encrypt("api key", "user 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if old.NeedsRotation(value) {
+		t.Fatal("value of the primary key needs rotation")
+	}
+	if !old.NeedsRotation("legacy key") {
+		t.Fatal("plaintext doesn't need rotation")
+	}
+
+	rotated := newTestKeyring(t, "b")
+	if !rotated.NeedsRotation(value) {
+		t.Fatal("value of the old key doesn't need rotation")
+	}
+
+	plaintext, err := rotated.Decrypt(value, "user 1")
+	if err != nil || plaintext != "api key" {
+		t.Fatalf("value of the old key isn't decrypted: %q, %v", plaintext, err)
+	}
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		primaryId string
+		keys      map[string]string
+	}{
+		{name: "unknown primary key", primaryId: "c", keys: map[string]string{"a": testKey('a')}},
+		{name: "bad key id", primaryId: "a:b", keys: map[string]string{"a:b": testKey('a')}},
+		{name: "bad base64", primaryId: "a", keys: map[string]string{"a": "!!"}},
+		{name: "bad key size", primaryId: "a", keys: map[string]string{"a": base64.StdEncoding.EncodeToString([]byte("short"))}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewKeyring(test.primaryId, test.keys)
+			if err == nil {
+				t.Fatal("keyring is created")
+			}
+		})
+	}
+}
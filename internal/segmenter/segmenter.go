@@ -0,0 +1,102 @@
+package segmenter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// boundary reports whether text may be cut between prev and r.
+type boundary func(prev rune, r rune) bool
+
+// boundaries are ordered from the most to the least preferable place to cut.
+var boundaries = []boundary{
+	// paragraphs
+	func(prev rune, r rune) bool { return prev == '\n' && r != '\n' },
+	// sentences
+	func(prev rune, r rune) bool { return strings.ContainsRune(".!?…;", prev) && unicode.IsSpace(r) },
+	// words
+	func(prev rune, r rune) bool { return unicode.IsSpace(prev) && !unicode.IsSpace(r) },
+}
+
+// Split cuts text into chunks of at most limit characters. It cuts on paragraph
+// boundaries where possible, then on sentences, then on words, and cuts words
+// only when a single word is longer than limit.
+func Split(text string, limit int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+
+	chunks := []string{}
+	current := ""
+
+	flush := func() {
+		chunk := strings.TrimSpace(current)
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current = ""
+	}
+
+	for _, unit := range units(text, limit, 0) {
+		if utf8.RuneCountInString(strings.TrimSpace(current+unit)) > limit {
+			flush()
+		}
+		current += unit
+	}
+	flush()
+
+	return chunks
+}
+
+// units splits text into parts no longer than limit, using boundaries starting
+// from level. Concatenation of the parts is always equal to text.
+func units(text string, limit int, level int) []string {
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+
+	if level == len(boundaries) {
+		return cut(text, limit)
+	}
+
+	result := []string{}
+	for _, part := range splitOn(text, boundaries[level]) {
+		result = append(result, units(part, limit, level+1)...)
+	}
+
+	return result
+}
+
+func splitOn(text string, isBoundary boundary) []string {
+	parts := []string{}
+	start := 0
+	prev := rune(-1)
+
+	for i, r := range text {
+		if prev != -1 && isBoundary(prev, r) {
+			parts = append(parts, text[start:i])
+			start = i
+		}
+		prev = r
+	}
+
+	return append(parts, text[start:])
+}
+
+func cut(text string, limit int) []string {
+	parts := []string{}
+	runes := []rune(text)
+
+	for len(runes) > limit {
+		parts = append(parts, string(runes[:limit]))
+		runes = runes[limit:]
+	}
+
+	return append(parts, string(runes))
+}
@@ -0,0 +1,82 @@
+package segmenter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{name: "empty", text: "", limit: 10, want: nil},
+		{name: "spaces only", text: " \n\t ", limit: 10, want: nil},
+		{name: "no limit", text: "one two", limit: 0, want: []string{"one two"}},
+		{name: "at limit", text: "0123456789", limit: 10, want: []string{"0123456789"}},
+		{name: "over limit by one", text: "01234 6789a", limit: 10, want: []string{"01234", "6789a"}},
+		{
+			name:  "paragraphs first",
+			text:  "One. Two.\nThree four.",
+			limit: 12,
+			want:  []string{"One. Two.", "Three four."},
+		},
+		{
+			name:  "sentences",
+			text:  "First one. Second one! Third?",
+			limit: 22,
+			want:  []string{"First one. Second one!", "Third?"},
+		},
+		{
+			name:  "no sentence breaks",
+			text:  "alpha beta gamma delta",
+			limit: 11,
+			want:  []string{"alpha beta", "gamma delta"},
+		},
+		{
+			name:  "word longer than limit",
+			text:  "abcdefghij k",
+			limit: 4,
+			want:  []string{"abcd", "efgh", "ij k"},
+		},
+		{
+			name:  "multi-byte runes",
+			text:  "Привет мир. Как дела?",
+			limit: 11,
+			want:  []string{"Привет мир.", "Как дела?"},
+		},
+		{
+			name:  "multi-byte word cut",
+			text:  "ёёёёёё",
+			limit: 4,
+			want:  []string{"ёёёё", "ёё"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Split(test.text, test.limit)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("Split(%q, %v) = %q, want %q", test.text, test.limit, got, test.want)
+			}
+
+			for _, chunk := range got {
+				if test.limit > 0 && utf8.RuneCountInString(chunk) > test.limit {
+					t.Fatalf("chunk %q is longer than %v", chunk, test.limit)
+				}
+				if !utf8.ValidString(chunk) {
+					t.Fatalf("chunk %q isn't valid utf-8", chunk)
+				}
+			}
+
+			// chunks keep all text but whitespace at the cuts
+			if strings.Join(strings.Fields(strings.Join(got, "")), "") != strings.Join(strings.Fields(test.text), "") {
+				t.Fatalf("chunks %q lose text of %q", got, test.text)
+			}
+		})
+	}
+}
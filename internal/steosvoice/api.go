@@ -1,10 +1,10 @@
 package steosvoice
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
+	"context"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 const (
@@ -12,7 +12,7 @@ const (
 	GET_SYMBOLS_URL            = "https://api.voice.steos.io/v1/get/symbols"
 	GET_TARIFFS_URL            = "https://api.voice.steos.io/v1/get/tariffs"
 	GET_SYNTHESIZED_SPEECH_URL = "https://api.voice.steos.io/v1/get/tts"
-	FORMAT                     = "mp3"
+	DEFAULT_FORMAT             = "mp3"
 )
 
 type Voice struct {
@@ -35,6 +35,20 @@ type GetSymbolsResp struct {
 	Symbols int64  `json:"symbols,omitempty"`
 }
 
+type Tariff struct {
+	Id       int64             `json:"tariff_id,omitempty"`
+	Name     map[string]string `json:"name,omitempty"`
+	Price    float64           `json:"price,omitempty"`
+	Currency string            `json:"currency,omitempty"`
+	Symbols  int64             `json:"symbols,omitempty"`
+}
+
+type GetTariffsResp struct {
+	Status  bool      `json:"status,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Tariffs []*Tariff `json:"tariffs,omitempty"`
+}
+
 type StatusResp struct {
 	Status  bool   `json:"status,omitempty"`
 	Message string `json:"message,omitempty"`
@@ -49,52 +63,39 @@ type GetSynthSpeechResp struct {
 }
 
 type GetSynthSpeechReq struct {
-	VoiceId int64  `json:"voice_id,omitempty"`
-	Text    string `json:"text,omitempty"`
-	Format  string `json:"format,omitempty"`
+	VoiceId int64   `json:"voice_id,omitempty"`
+	Text    string  `json:"text,omitempty"`
+	Format  string  `json:"format,omitempty"`
+	Speed   float64 `json:"speed,omitempty"`
+	Pitch   float64 `json:"pitch,omitempty"`
+	Volume  float64 `json:"volume,omitempty"`
 }
 
 type SteosVoiceAPI struct {
-	client *http.Client
+	client      *http.Client
+	callTimeout time.Duration
+	maxRetries  int
+	backoff     time.Duration
+	logger      *slog.Logger
 }
 
-func NewSteosVoiceAPI(client *http.Client) *SteosVoiceAPI {
-	return &SteosVoiceAPI{client: client}
-}
-
-func (s *SteosVoiceAPI) GetVoices(apiKey string) (*GetVoiceResp, error) {
-	req, err := http.NewRequest("GET", GET_VOICES_URL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", apiKey)
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	// if status false, the service returns strings in all fields :(
-	strep := &StatusResp{}
-	err = json.Unmarshal(body, strep)
-	if err != nil {
-		return nil, err
-	}
-
-	if !strep.Status {
-		return &GetVoiceResp{Status: strep.Status, Message: strep.Message}, nil
+// NewSteosVoiceAPI limits every call attempt with callTimeout, zero leaves
+// attempts without a deadline of their own. Transport
+// errors, timeouts and 5xx responses are retried up to maxRetries times with
+// exponential backoff starting from backoff.
+func NewSteosVoiceAPI(client *http.Client, callTimeout time.Duration, maxRetries int, backoff time.Duration, logger *slog.Logger) *SteosVoiceAPI {
+	return &SteosVoiceAPI{
+		client:      client,
+		callTimeout: callTimeout,
+		maxRetries:  maxRetries,
+		backoff:     backoff,
+		logger:      logger,
 	}
+}
 
+func (s *SteosVoiceAPI) GetVoices(ctx context.Context, apiKey string) (*GetVoiceResp, error) {
 	gvrep := &GetVoiceResp{}
-	err = json.Unmarshal(body, gvrep)
+	err := s.do(ctx, http.MethodGet, GET_VOICES_URL, apiKey, nil, gvrep)
 	if err != nil {
 		return nil, err
 	}
@@ -102,39 +103,9 @@ func (s *SteosVoiceAPI) GetVoices(apiKey string) (*GetVoiceResp, error) {
 	return gvrep, nil
 }
 
-func (s *SteosVoiceAPI) GetSymbols(apiKey string) (*GetSymbolsResp, error) {
-	req, err := http.NewRequest("GET", GET_SYMBOLS_URL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", apiKey)
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	// if status false, the service returns strings in all fields :(
-	strep := &StatusResp{}
-	err = json.Unmarshal(body, strep)
-	if err != nil {
-		return nil, err
-	}
-
-	if !strep.Status {
-		return &GetSymbolsResp{Status: strep.Status, Message: strep.Message}, nil
-	}
-
+func (s *SteosVoiceAPI) GetSymbols(ctx context.Context, apiKey string) (*GetSymbolsResp, error) {
 	gsrep := &GetSymbolsResp{}
-	err = json.Unmarshal(body, gsrep)
+	err := s.do(ctx, http.MethodGet, GET_SYMBOLS_URL, apiKey, nil, gsrep)
 	if err != nil {
 		return nil, err
 	}
@@ -142,46 +113,23 @@ func (s *SteosVoiceAPI) GetSymbols(apiKey string) (*GetSymbolsResp, error) {
 	return gsrep, nil
 }
 
-func (s *SteosVoiceAPI) GetSynthesizedSpeech(apiKey string, text string, voiceId int64) (*GetSynthSpeechResp, error) {
-	gssBody := &GetSynthSpeechReq{VoiceId: voiceId, Text: text, Format: FORMAT}
-	reqBody, err := json.Marshal(gssBody)
-	if err != nil {
-		return nil, err
-	}
-	jsonBytes := bytes.NewBuffer(reqBody)
-
-	req, err := http.NewRequest("POST", GET_SYNTHESIZED_SPEECH_URL, jsonBytes)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", apiKey)
-
-	resp, err := s.client.Do(req)
+func (s *SteosVoiceAPI) GetTariffs(ctx context.Context, apiKey string) (*GetTariffsResp, error) {
+	gtrep := &GetTariffsResp{}
+	err := s.do(ctx, http.MethodGet, GET_TARIFFS_URL, apiKey, nil, gtrep)
 	if err != nil {
 		return nil, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	// if status false, the service returns strings in all fields :(
-	strep := &StatusResp{}
-	err = json.Unmarshal(body, strep)
-	if err != nil {
-		return nil, err
-	}
+	return gtrep, nil
+}
 
-	if !strep.Status {
-		return &GetSynthSpeechResp{Status: strep.Status, Message: strep.Message}, nil
+func (s *SteosVoiceAPI) GetSynthesizedSpeech(ctx context.Context, apiKey string, gssBody *GetSynthSpeechReq) (*GetSynthSpeechResp, error) {
+	if gssBody.Format == "" {
+		gssBody.Format = DEFAULT_FORMAT
 	}
 
 	gssrep := &GetSynthSpeechResp{}
-	err = json.Unmarshal(body, gssrep)
+	err := s.do(ctx, http.MethodPost, GET_SYNTHESIZED_SPEECH_URL, apiKey, gssBody, gssrep)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,110 @@
+package steosvoice
+
+import (
+	"context"
+
+	"github.com/Quiexx/narrator-bot/internal/tts"
+)
+
+const (
+	PROVIDER_NAME = "steosvoice"
+
+	NOT_ENOUGH_SYMBOLS_ERROR = "Not enough symbols"
+	CONNECTION_TIMEOUT_ERROR = "Connection timeout"
+)
+
+var formats = []string{"mp3", "wav", "ogg"}
+
+var paramLimits = tts.ParamLimits{
+	Speed:  tts.Range{Min: 0.5, Max: 2, Step: 0.25, Default: 1},
+	Pitch:  tts.Range{Min: -10, Max: 10, Step: 1, Default: 0},
+	Volume: tts.Range{Min: 0.5, Max: 2, Step: 0.25, Default: 1},
+}
+
+var _ tts.Provider = (*SteosVoiceAPI)(nil)
+
+func (s *SteosVoiceAPI) Name() string {
+	return PROVIDER_NAME
+}
+
+func (s *SteosVoiceAPI) Voices(ctx context.Context, apiKey string) ([]*tts.Voice, error) {
+	result, err := s.GetVoices(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	voices := make([]*tts.Voice, 0, len(result.Voices))
+	for _, voice := range result.Voices {
+		voices = append(voices, &tts.Voice{
+			Id:          voice.Id,
+			Name:        voice.Name,
+			Description: voice.Description,
+			LangId:      voice.LangId,
+			Sex:         voice.Sex,
+		})
+	}
+
+	return voices, nil
+}
+
+func (s *SteosVoiceAPI) Balance(ctx context.Context, apiKey string) (int64, error) {
+	result, err := s.GetSymbols(ctx, apiKey)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Symbols, nil
+}
+
+func (s *SteosVoiceAPI) Tariffs(ctx context.Context, apiKey string) ([]*tts.Tariff, error) {
+	result, err := s.GetTariffs(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tariffs := make([]*tts.Tariff, 0, len(result.Tariffs))
+	for _, tariff := range result.Tariffs {
+		tariffs = append(tariffs, &tts.Tariff{
+			Id:       tariff.Id,
+			Name:     tariff.Name,
+			Price:    tariff.Price,
+			Currency: tariff.Currency,
+			Symbols:  tariff.Symbols,
+		})
+	}
+
+	return tariffs, nil
+}
+
+func (s *SteosVoiceAPI) Formats() []string {
+	return formats
+}
+
+func (s *SteosVoiceAPI) ParamLimits() tts.ParamLimits {
+	return paramLimits
+}
+
+func (s *SteosVoiceAPI) Synthesize(ctx context.Context, apiKey string, text string, voiceId int64, format string, params tts.SpeechParams) (*tts.Speech, error) {
+	err := paramLimits.Validate(params)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.GetSynthesizedSpeech(ctx, apiKey, &GetSynthSpeechReq{
+		VoiceId: voiceId,
+		Text:    text,
+		Format:  format,
+		Speed:   params.Speed,
+		Pitch:   params.Pitch,
+		Volume:  params.Volume,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &tts.Speech{
+		VoiceId:  result.VoiceId,
+		AudioUrl: result.AudioUrl,
+		Format:   result.Format,
+	}, nil
+}
@@ -0,0 +1,233 @@
+package steosvoice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/logging"
+	"github.com/Quiexx/narrator-bot/internal/metrics"
+	"github.com/Quiexx/narrator-bot/internal/tts"
+)
+
+const (
+	MAX_RESPONSE_SIZE = 10 << 20
+	MAX_BACKOFF       = 30 * time.Second
+
+	DIAL_TIMEOUT          = 10 * time.Second
+	TLS_HANDSHAKE_TIMEOUT = 10 * time.Second
+	IDLE_CONN_TIMEOUT     = 90 * time.Second
+)
+
+// NewHTTPClient bounds connecting to the service, so a call without a
+// deadline can't hang on an unreachable host.
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         (&net.Dialer{Timeout: DIAL_TIMEOUT, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout: TLS_HANDSHAKE_TIMEOUT,
+			IdleConnTimeout:     IDLE_CONN_TIMEOUT,
+			ForceAttemptHTTP2:   true,
+		},
+	}
+}
+
+// APIError describes a failed call. Kind is one of the tts.Err* values, so
+// callers can tell auth failures, quota exhaustion and transport errors apart
+// with errors.Is.
+type APIError struct {
+	Kind       error
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("steosvoice: %v: %v", e.Kind, e.Err)
+	case e.StatusCode != 0:
+		return fmt.Sprintf("steosvoice: %v: status %v: %v", e.Kind, e.StatusCode, e.Message)
+	default:
+		return fmt.Sprintf("steosvoice: %v: %v", e.Kind, e.Message)
+	}
+}
+
+func (e *APIError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// do sends the request, retrying it while it's retryable, and decodes the
+// response into out.
+func (s *SteosVoiceAPI) do(ctx context.Context, method string, url string, apiKey string, reqBody interface{}, out interface{}) error {
+	var payload []byte
+	if reqBody != nil {
+		var err error
+		payload, err = json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("steosvoice: encode request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err := s.doOnce(ctx, method, url, apiKey, payload, out)
+		metrics.Since(metrics.ProviderRequestDuration.WithLabelValues(PROVIDER_NAME, endpoint(url), metrics.Result(err)), start)
+		if err == nil || !retryable(method, err) || attempt >= s.maxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		delay := s.backoffDelay(attempt)
+		logging.FromContext(ctx, s.logger).Warn(
+			"retrying steosvoice request",
+			"endpoint", endpoint(url),
+			"attempt", attempt+1,
+			"delay", delay,
+			"error", err,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *SteosVoiceAPI) doOnce(ctx context.Context, method string, url string, apiKey string, payload []byte, out interface{}) error {
+	if s.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.callTimeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("steosvoice: build request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", apiKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &APIError{Kind: tts.ErrTransport, Err: err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, MAX_RESPONSE_SIZE))
+	if err != nil {
+		return &APIError{Kind: tts.ErrTransport, Err: err}
+	}
+
+	// if status false, the service returns strings in all fields :(
+	strep := &StatusResp{}
+	_ = json.Unmarshal(respBody, strep)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{Kind: statusCodeKind(resp.StatusCode), StatusCode: resp.StatusCode, Message: strep.Message}
+	}
+
+	err = json.Unmarshal(respBody, strep)
+	if err != nil {
+		return &APIError{Kind: tts.ErrRejected, StatusCode: resp.StatusCode, Message: "malformed response", Err: err}
+	}
+
+	if !strep.Status {
+		return &APIError{Kind: messageKind(strep.Message), StatusCode: resp.StatusCode, Message: strep.Message}
+	}
+
+	err = json.Unmarshal(respBody, out)
+	if err != nil {
+		return &APIError{Kind: tts.ErrRejected, StatusCode: resp.StatusCode, Message: "malformed response", Err: err}
+	}
+
+	return nil
+}
+
+// backoffDelay doubles the delay with every attempt and picks a random delay
+// from its upper half.
+func (s *SteosVoiceAPI) backoffDelay(attempt int) time.Duration {
+	if s.backoff <= 0 {
+		return 0
+	}
+
+	delay := s.backoff << attempt
+	// the shift overflows after enough attempts
+	if delay>>attempt != s.backoff || delay > MAX_BACKOFF {
+		delay = MAX_BACKOFF
+	}
+
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// endpoint labels metrics with the url path, which has no user data.
+func endpoint(rawUrl string) string {
+	parsed, err := neturl.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return parsed.Path
+}
+
+// retryable reports whether a failed request can be sent again. GET requests
+// are retried on transport errors, timeouts and 5xx responses. Others aren't
+// idempotent, a synthesis charges symbols, so they are retried only if they
+// never reached the service.
+func retryable(method string, err error) bool {
+	if method != http.MethodGet {
+		return notSent(err)
+	}
+	return errors.Is(err, tts.ErrTransport) || errors.Is(err, tts.ErrUnavailable)
+}
+
+// notSent reports whether the connection to the service couldn't be made.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func statusCodeKind(statusCode int) error {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return tts.ErrUnauthorized
+	case statusCode == http.StatusPaymentRequired:
+		return tts.ErrNotEnoughSymbols
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		return tts.ErrUnavailable
+	default:
+		return tts.ErrRejected
+	}
+}
+
+func messageKind(message string) error {
+	switch message {
+	case NOT_ENOUGH_SYMBOLS_ERROR:
+		return tts.ErrNotEnoughSymbols
+	case CONNECTION_TIMEOUT_ERROR:
+		return tts.ErrUnavailable
+	default:
+		return tts.ErrRejected
+	}
+}
@@ -0,0 +1,152 @@
+package steosvoice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/tts"
+)
+
+func TestRetryable(t *testing.T) {
+	dialErr := &APIError{Kind: tts.ErrTransport, Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}
+	readErr := &APIError{Kind: tts.ErrTransport, Err: &net.OpError{Op: "read", Err: errors.New("reset")}}
+	unavailable := &APIError{Kind: tts.ErrUnavailable, StatusCode: http.StatusBadGateway}
+	rejected := &APIError{Kind: tts.ErrRejected, StatusCode: http.StatusBadRequest}
+
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		want   bool
+	}{
+		{name: "get dial error", method: http.MethodGet, err: dialErr, want: true},
+		{name: "get read error", method: http.MethodGet, err: readErr, want: true},
+		{name: "get unavailable", method: http.MethodGet, err: unavailable, want: true},
+		{name: "get rejected", method: http.MethodGet, err: rejected},
+		{name: "post dial error", method: http.MethodPost, err: dialErr, want: true},
+		{name: "post read error", method: http.MethodPost, err: readErr},
+		{name: "post unavailable", method: http.MethodPost, err: unavailable},
+		{name: "post rejected", method: http.MethodPost, err: rejected},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := retryable(test.method, test.err); got != test.want {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStatusCodeKind(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       error
+	}{
+		{statusCode: http.StatusTooManyRequests, want: tts.ErrUnavailable},
+		{statusCode: http.StatusInternalServerError, want: tts.ErrUnavailable},
+		{statusCode: http.StatusServiceUnavailable, want: tts.ErrUnavailable},
+		{statusCode: http.StatusUnauthorized, want: tts.ErrUnauthorized},
+		{statusCode: http.StatusForbidden, want: tts.ErrUnauthorized},
+		{statusCode: http.StatusPaymentRequired, want: tts.ErrNotEnoughSymbols},
+		{statusCode: http.StatusBadRequest, want: tts.ErrRejected},
+		{statusCode: http.StatusNotFound, want: tts.ErrRejected},
+	}
+
+	for _, test := range tests {
+		if got := statusCodeKind(test.statusCode); got != test.want {
+			t.Fatalf("status %v: got %v, want %v", test.statusCode, got, test.want)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	s := &SteosVoiceAPI{backoff: 100 * time.Millisecond}
+
+	for attempt := 0; attempt < 100; attempt++ {
+		// 100ms doubled 9 times is over MAX_BACKOFF
+		max := MAX_BACKOFF
+		if attempt < 9 {
+			max = s.backoff << attempt
+		}
+
+		for i := 0; i < 20; i++ {
+			delay := s.backoffDelay(attempt)
+			if delay < max/2 || delay > max {
+				t.Fatalf("attempt %v: delay %v out of [%v, %v]", attempt, delay, max/2, max)
+			}
+		}
+	}
+
+	s.backoff = 0
+	if delay := s.backoffDelay(3); delay != 0 {
+		t.Fatalf("got %v without backoff", delay)
+	}
+}
+
+func newTestAPI(t *testing.T, callTimeout time.Duration, handler http.HandlerFunc) (*SteosVoiceAPI, string) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewSteosVoiceAPI(server.Client(), callTimeout, 3, 0, logger), server.URL + "/v1/get/voices"
+}
+
+func TestDoStatusErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		statusCode int
+		kind       error
+		calls      int64
+	}{
+		{name: "get 503 is retried", method: http.MethodGet, statusCode: http.StatusServiceUnavailable, kind: tts.ErrUnavailable, calls: 4},
+		{name: "post 503 isn't retried", method: http.MethodPost, statusCode: http.StatusServiceUnavailable, kind: tts.ErrUnavailable, calls: 1},
+		{name: "get 429 is retried", method: http.MethodGet, statusCode: http.StatusTooManyRequests, kind: tts.ErrUnavailable, calls: 4},
+		{name: "get 400 isn't retried", method: http.MethodGet, statusCode: http.StatusBadRequest, kind: tts.ErrRejected, calls: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls atomic.Int64
+			s, url := newTestAPI(t, time.Second, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(test.statusCode)
+				io.WriteString(w, `{"status": "false", "message": "nope"}`)
+			})
+
+			err := s.do(context.Background(), test.method, url, "key", nil, &StatusResp{})
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || !errors.Is(err, test.kind) || apiErr.StatusCode != test.statusCode {
+				t.Fatalf("got %v, want %v with status %v", err, test.kind, test.statusCode)
+			}
+			if apiErr.Message != "nope" {
+				t.Fatalf("message %q isn't passed through", apiErr.Message)
+			}
+			if calls.Load() != test.calls {
+				t.Fatalf("got %v calls, want %v", calls.Load(), test.calls)
+			}
+		})
+	}
+}
+
+func TestDoWithoutCallTimeout(t *testing.T) {
+	s, url := newTestAPI(t, 0, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"status": true, "message": "ok"}`)
+	})
+
+	out := &StatusResp{}
+	err := s.do(context.Background(), http.MethodGet, url, "key", nil, out)
+	if err != nil || out.Message != "ok" {
+		t.Fatalf("got %+v, %v", out, err)
+	}
+}
@@ -0,0 +1,146 @@
+package templates
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	RU = "ru"
+	EN = "en"
+
+	// DEFAULT_LANGUAGE is used when telegram doesn't tell the user's language,
+	// FALLBACK_LANGUAGE when there is no catalog for it.
+	DEFAULT_LANGUAGE  = RU
+	FALLBACK_LANGUAGE = EN
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+type catalog struct {
+	Name          string            `json:"name"`
+	Messages      map[string]string `json:"messages"`
+	StartMessages []string          `json:"start_messages"`
+}
+
+// catalogs by language code, e.g. locales/en.json is en
+var catalogs = mustLoadCatalogs()
+
+type ctxKey struct{}
+
+func mustLoadCatalogs() map[string]*catalog {
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := map[string]*catalog{}
+	for _, file := range files {
+		data, err := locales.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		c := &catalog{}
+		err = json.Unmarshal(data, c)
+		if err != nil {
+			panic(fmt.Sprintf("parse catalog %v: %v", file.Name(), err))
+		}
+
+		loaded[strings.TrimSuffix(file.Name(), path.Ext(file.Name()))] = c
+	}
+
+	if loaded[DEFAULT_LANGUAGE] == nil || loaded[FALLBACK_LANGUAGE] == nil {
+		panic("no catalog for default or fallback language")
+	}
+
+	return loaded
+}
+
+// Languages returns codes of languages having a catalog.
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// LanguageName returns the name of lang in lang itself.
+func LanguageName(lang string) string {
+	c, ok := catalogs[lang]
+	if !ok {
+		return lang
+	}
+	return c.Name
+}
+
+// Supported reports whether there is a catalog for lang.
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Match returns the catalog language for a telegram language_code, which is
+// an IETF language tag like "en" or "pt-br".
+func Match(languageCode string) string {
+	if languageCode == "" {
+		return DEFAULT_LANGUAGE
+	}
+
+	lang := strings.ToLower(strings.SplitN(languageCode, "-", 2)[0])
+	if Supported(lang) {
+		return lang
+	}
+	return FALLBACK_LANGUAGE
+}
+
+// WithLanguage attaches the language messages are sent in to ctx.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, lang)
+}
+
+// Language returns the language attached to ctx or DEFAULT_LANGUAGE.
+func Language(ctx context.Context) string {
+	lang, ok := ctx.Value(ctxKey{}).(string)
+	if !ok {
+		return DEFAULT_LANGUAGE
+	}
+	return lang
+}
+
+// Text returns the message with key in the language attached to ctx.
+func Text(ctx context.Context, key string) string {
+	return Lookup(Language(ctx), key)
+}
+
+// Lookup returns the message with key in lang. Messages missing in lang are
+// taken from the default catalog.
+func Lookup(lang string, key string) string {
+	if c, ok := catalogs[lang]; ok {
+		if text, ok := c.Messages[key]; ok {
+			return text
+		}
+	}
+
+	if text, ok := catalogs[DEFAULT_LANGUAGE].Messages[key]; ok {
+		return text
+	}
+	return key
+}
+
+// StartMessages returns the /start messages in the language attached to ctx.
+func StartMessages(ctx context.Context) []string {
+	c, ok := catalogs[Language(ctx)]
+	if !ok {
+		c = catalogs[DEFAULT_LANGUAGE]
+	}
+	return c.StartMessages
+}
@@ -1,29 +1,113 @@
 package templates
 
+// Message keys of the catalogs in locales.
 const (
-	FAIL_MESSAGE                 = "У меня не получилось прочитать твое сообщение 😔\n"
-	FAIL_COMMAND_MESSAGE         = "У меня не получилось выполнить команду 😔\n"
-	SOMETHING_GONE_WRONG_MESSAGE = "Ой, кажется, что-то пошло не так 😔\n"
-	UNKNOWN_COMMAND_MESSAGE      = "Я пока не умею обрабатывать эту команду 😔\n"
-	SYMBOL_COUNT_MESSAGE         = "🤓 Я могу прочитать для тебя еще столько символов: %v"
-	NO_API_KEY_MESSAGE           = "Сначала тебе нужно добавить ключ для сервиса [cybervoice.io](https://cybervoice.io/ru/)\nНайти его можно в [личном кабинете](https://console.cybervoice.io/user) 😉"
-	NO_VOICE_MESSAGE             = "Сначала тебе нужно выбрать голос, которым я буду озвучивать сообщения. Для этого вызови команду /voice"
-
-	SET_API_KEY_MESSAGE              = "Отправь API ключ в следующем сообщении"
-	NOT_API_KEY_MESSAGE              = "Кажется, вы прислали не API ключ 😳.\nКлюч должен быть строкой, получить его можно в личном кабинете steosvoice."
-	FAILD_TO_CONNECT_API_KEY_MESSAGE = "У меня не получилось подключить ваш аккаунт steosvoice 😔\nПроверьте, что ключ введен правильно, и попробуйте еще раз."
-	API_KEY_IS_SET_MESSAGE           = "Ключ добавлен 🤗 Теперь отправь мне сообщение, и я его озвучу 😉"
-	VOICE_LIST_MESSAGE               = "Вот голоса, которые тебе доступны. Нажми на кнопку, чтобы прочитать описание голоса и выбрать его 🤠"
-	VOICE_IS_SET_MESSAGE             = "Голос выбран 🤗\nТеперь напиши мне что-нибудь 😉"
-	NOT_ENOUGH_SYMBOLS               = "Кажется, у тебя не хватает символов для озвучки этого сообщения 😓\nЧтобы посмотреть, сколько символов для озвучки тебе доступно, вызови команду /symbols"
-	SERVICE_NOT_AVAILABLE            = "Похоже, [cybervoice.io] мне не отвечает 😓\nПопробуй написать позже"
-	CAN_NOT_HANDLE                   = "Ты прислал мне что-то, что я не могу обработать 🤯"
+	FAIL_MESSAGE                 = "fail_message"
+	FAIL_COMMAND_MESSAGE         = "fail_command_message"
+	SOMETHING_GONE_WRONG_MESSAGE = "something_gone_wrong_message"
+	UNKNOWN_COMMAND_MESSAGE      = "unknown_command_message"
+	SYMBOL_COUNT_MESSAGE         = "symbol_count_message"
+	NO_API_KEY_MESSAGE           = "no_api_key_message"
+	NO_VOICE_MESSAGE             = "no_voice_message"
+
+	SET_API_KEY_MESSAGE              = "set_api_key_message"
+	NOT_API_KEY_MESSAGE              = "not_api_key_message"
+	FAILD_TO_CONNECT_API_KEY_MESSAGE = "faild_to_connect_api_key_message"
+	API_KEY_IS_SET_MESSAGE           = "api_key_is_set_message"
+	VOICE_LIST_MESSAGE               = "voice_list_message"
+	VOICE_IS_SET_MESSAGE             = "voice_is_set_message"
+	NOT_ENOUGH_SYMBOLS               = "not_enough_symbols"
+	SERVICE_NOT_AVAILABLE            = "service_not_available"
+	INVALID_API_KEY_MESSAGE          = "invalid_api_key_message"
+	BUSY_MESSAGE                     = "busy_message"
+	CAN_NOT_HANDLE                   = "can_not_handle"
+	NARRATION_PROGRESS_MESSAGE       = "narration_progress_message"
+	NARRATION_DONE_MESSAGE           = "narration_done_message"
+	NARRATION_ABORTED_MESSAGE        = "narration_aborted_message"
+	NARRATION_FAILED_MESSAGE         = "narration_failed_message"
+	NARRATION_ABORT_BUTTON           = "narration_abort_button"
+	HISTORY_LIST_MESSAGE             = "history_list_message"
+	EMPTY_HISTORY_MESSAGE            = "empty_history_message"
+
+	TARIFFS_MESSAGE    = "tariffs_message"
+	TARIFF_LINE        = "tariff_line"
+	NO_TARIFFS_MESSAGE = "no_tariffs_message"
+	BUY_SYMBOLS_BUTTON = "buy_symbols_button"
+
+	FORMAT_LIST_MESSAGE   = "format_list_message"
+	FORMAT_IS_SET_MESSAGE = "format_is_set_message"
+	VOICE_FORMAT_BUTTON   = "voice_format_button"
+	FILE_FORMAT_BUTTON    = "file_format_button"
+
+	SETTINGS_MESSAGE        = "settings_message"
+	SETTINGS_DEFAULT_VALUE  = "settings_default_value"
+	SETTINGS_SPEED_BUTTON   = "settings_speed_button"
+	SETTINGS_PITCH_BUTTON   = "settings_pitch_button"
+	SETTINGS_VOLUME_BUTTON  = "settings_volume_button"
+	SETTINGS_EMOTION_BUTTON = "settings_emotion_button"
+	SETTINGS_RESET_BUTTON   = "settings_reset_button"
+
+	GROUP_ONLY_COMMAND_MESSAGE   = "group_only_command_message"
+	PRIVATE_ONLY_COMMAND_MESSAGE = "private_only_command_message"
+	CHAT_NOT_CONFIGURED_MESSAGE  = "chat_not_configured_message"
+	CHAT_ADMIN_ONLY_MESSAGE      = "chat_admin_only_message"
+	CHAT_OWNER_NO_API_KEY        = "chat_owner_no_api_key"
+	CHAT_KEY_IS_SET_MESSAGE      = "chat_key_is_set_message"
+	CHAT_KEY_REMOVED_MESSAGE     = "chat_key_removed_message"
+	SAY_USAGE_MESSAGE            = "say_usage_message"
+
+	THROTTLED_MESSAGE        = "throttled_message"
+	GLOBAL_THROTTLED_MESSAGE = "global_throttled_message"
+	BLOCK_USAGE_MESSAGE      = "block_usage_message"
+	USER_BLOCKED_MESSAGE     = "user_blocked_message"
+	USER_UNBLOCKED_MESSAGE   = "user_unblocked_message"
+
+	INLINE_RESULT_TITLE         = "inline_result_title"
+	INLINE_CHOOSE_VOICE         = "inline_choose_voice"
+	INLINE_NO_API_KEY           = "inline_no_api_key"
+	INLINE_NOT_ENOUGH_SYMBOLS   = "inline_not_enough_symbols"
+	INLINE_SOMETHING_GONE_WRONG = "inline_something_gone_wrong"
+	INLINE_DISABLED             = "inline_disabled"
+
+	PAGE_MESSAGE              = "page_message"
+	VOICE_DESCRIPTION_MESSAGE = "voice_description_message"
+	UNKNOWN_VALUE             = "unknown_value"
+	CHOOSE_VOICE_BUTTON       = "choose_voice_button"
+	BACK_BUTTON               = "back_button"
+
+	VOICES_FOUND_MESSAGE    = "voices_found_message"
+	VOICE_QUERY_MESSAGE     = "voice_query_message"
+	VOICE_QUERY_CUT_MESSAGE = "voice_query_cut_message"
+	FAVORITE_VOICES_BUTTON  = "favorite_voices_button"
+	RESET_FILTERS_BUTTON    = "reset_filters_button"
+	ADD_FAVORITE_BUTTON     = "add_favorite_button"
+	REMOVE_FAVORITE_BUTTON  = "remove_favorite_button"
+
+	LANGUAGE_LIST_MESSAGE   = "language_list_message"
+	LANGUAGE_IS_SET_MESSAGE = "language_is_set_message"
+	LANGUAGE_AUTO_BUTTON    = "language_auto_button"
+
+	CANCELLED_MESSAGE         = "cancelled_message"
+	NOTHING_TO_CANCEL_MESSAGE = "nothing_to_cancel_message"
+	STATE_EXPIRED_MESSAGE     = "state_expired_message"
+
+	STALE_BUTTON_MESSAGE = "stale_button_message"
+
+	HELP_MESSAGE                    = "help_message"
+	HELP_ALIASES                    = "help_aliases"
+	START_COMMAND_DESCRIPTION       = "start_command_description"
+	HELP_COMMAND_DESCRIPTION        = "help_command_description"
+	API_KEY_COMMAND_DESCRIPTION     = "api_key_command_description"
+	VOICE_COMMAND_DESCRIPTION       = "voice_command_description"
+	GET_SYMBOLS_COMMAND_DESCRIPTION = "get_symbols_command_description"
+	HISTORY_COMMAND_DESCRIPTION     = "history_command_description"
+	TARIFFS_COMMAND_DESCRIPTION     = "tariffs_command_description"
+	FORMAT_COMMAND_DESCRIPTION      = "format_command_description"
+	SETTINGS_COMMAND_DESCRIPTION    = "settings_command_description"
+	LANGUAGE_COMMAND_DESCRIPTION    = "language_command_description"
+	CANCEL_COMMAND_DESCRIPTION      = "cancel_command_description"
+	SAY_COMMAND_DESCRIPTION         = "say_command_description"
+	CHAT_KEY_COMMAND_DESCRIPTION    = "chat_key_command_description"
 )
 
-var StartMessages = []string{
-	"Я умею озвучивать сообщения 🎤\nДостаточно только написать или переслать мне сообщение, и я озвучу его для тебя 🤗",
-	"Для озвучки я использую сервис [cybervoice.io](https://cybervoice.io/ru/) 🤖\nПоэтому сначала тебе нужно завести в нем аккаунт.",
-	"Затем вызови команду /apikey и напиши мне свой токен, который можешь найти в [личном кабинете](https://console.cybervoice.io/user) 💫",
-	"После успешного подключения своего аккаунта можешь вызвать команду /voice и выбрать голос, которым я буду для тебя озвучивать сообщения 😏",
-	"Чтобы узнать, сколько еще символов тебе доступно для озвучки, вызови команду /symbols 💬",
-}
+const BUY_SYMBOLS_URL = "https://console.cybervoice.io/user"
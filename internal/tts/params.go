@@ -0,0 +1,79 @@
+package tts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrInvalidParams = errors.New("invalid speech params")
+
+// SpeechParams tune synthesis. Zero values stand for the provider's defaults.
+type SpeechParams struct {
+	Speed   float64
+	Pitch   float64
+	Volume  float64
+	Emotion string
+}
+
+// Range of a numeric speech param. Zero range means the param isn't supported.
+type Range struct {
+	Min     float64
+	Max     float64
+	Step    float64
+	Default float64
+}
+
+func (r Range) Supported() bool {
+	return r.Step != 0
+}
+
+// Contains reports whether value is allowed. Zero is always allowed.
+func (r Range) Contains(value float64) bool {
+	if value == 0 {
+		return true
+	}
+	return r.Supported() && value >= r.Min && value <= r.Max
+}
+
+// Shift moves value by steps within the range, zero value starts from Default.
+func (r Range) Shift(value float64, steps int) float64 {
+	if value == 0 {
+		value = r.Default
+	}
+
+	value += float64(steps) * r.Step
+	value = math.Max(r.Min, math.Min(r.Max, value))
+
+	return math.Round(value/r.Step) * r.Step
+}
+
+type ParamLimits struct {
+	Speed    Range
+	Pitch    Range
+	Volume   Range
+	Emotions []string
+}
+
+func (l ParamLimits) Validate(params SpeechParams) error {
+	switch {
+	case !l.Speed.Contains(params.Speed):
+		return fmt.Errorf("%w: speed %v", ErrInvalidParams, params.Speed)
+	case !l.Pitch.Contains(params.Pitch):
+		return fmt.Errorf("%w: pitch %v", ErrInvalidParams, params.Pitch)
+	case !l.Volume.Contains(params.Volume):
+		return fmt.Errorf("%w: volume %v", ErrInvalidParams, params.Volume)
+	}
+
+	if params.Emotion == "" {
+		return nil
+	}
+
+	for _, emotion := range l.Emotions {
+		if emotion == params.Emotion {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: emotion %q", ErrInvalidParams, params.Emotion)
+}
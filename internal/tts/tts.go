@@ -0,0 +1,50 @@
+package tts
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrNotEnoughSymbols = errors.New("not enough symbols")
+	ErrUnavailable      = errors.New("service unavailable")
+	ErrTransport        = errors.New("transport error")
+	ErrRejected         = errors.New("request rejected")
+)
+
+type Voice struct {
+	Id          int64
+	Name        map[string]string
+	Description map[string]string
+	LangId      int64
+	Sex         string
+}
+
+type Tariff struct {
+	Id       int64
+	Name     map[string]string
+	Price    float64
+	Currency string
+	Symbols  int64
+}
+
+type Speech struct {
+	VoiceId  int64
+	AudioUrl string
+	Format   string
+}
+
+// Provider is a text-to-speech backend. Every call is authorized with the
+// user's own api key. Errors returned by providers wrap one of the Err*
+// values when the failure reason is known.
+type Provider interface {
+	Name() string
+	Voices(ctx context.Context, apiKey string) ([]*Voice, error)
+	Balance(ctx context.Context, apiKey string) (int64, error)
+	Tariffs(ctx context.Context, apiKey string) ([]*Tariff, error)
+	Formats() []string
+	ParamLimits() ParamLimits
+	// Synthesize validates params against ParamLimits
+	Synthesize(ctx context.Context, apiKey string, text string, voiceId int64, format string, params SpeechParams) (*Speech, error)
+}
@@ -0,0 +1,89 @@
+// Package ttstest provides a tts.Provider for tests.
+package ttstest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Quiexx/narrator-bot/internal/tts"
+)
+
+const PROVIDER_NAME = "fake"
+
+// Provider answers with the functions set in its fields, unset ones return
+// zero values. It records the api keys of calls.
+type Provider struct {
+	VoicesFunc     func(ctx context.Context, apiKey string) ([]*tts.Voice, error)
+	BalanceFunc    func(ctx context.Context, apiKey string) (int64, error)
+	TariffsFunc    func(ctx context.Context, apiKey string) ([]*tts.Tariff, error)
+	SynthesizeFunc func(ctx context.Context, apiKey string, text string, voiceId int64, format string, params tts.SpeechParams) (*tts.Speech, error)
+	FormatList     []string
+	Limits         tts.ParamLimits
+
+	mu    sync.Mutex
+	calls map[string][]string
+}
+
+// Calls returns the api keys method was called with.
+func (p *Provider) Calls(method string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string{}, p.calls[method]...)
+}
+
+func (p *Provider) record(method string, apiKey string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.calls == nil {
+		p.calls = map[string][]string{}
+	}
+	p.calls[method] = append(p.calls[method], apiKey)
+}
+
+func (p *Provider) Name() string {
+	return PROVIDER_NAME
+}
+
+func (p *Provider) Voices(ctx context.Context, apiKey string) ([]*tts.Voice, error) {
+	p.record("Voices", apiKey)
+	if p.VoicesFunc == nil {
+		return nil, nil
+	}
+	return p.VoicesFunc(ctx, apiKey)
+}
+
+func (p *Provider) Balance(ctx context.Context, apiKey string) (int64, error) {
+	p.record("Balance", apiKey)
+	if p.BalanceFunc == nil {
+		return 0, nil
+	}
+	return p.BalanceFunc(ctx, apiKey)
+}
+
+func (p *Provider) Tariffs(ctx context.Context, apiKey string) ([]*tts.Tariff, error) {
+	p.record("Tariffs", apiKey)
+	if p.TariffsFunc == nil {
+		return nil, nil
+	}
+	return p.TariffsFunc(ctx, apiKey)
+}
+
+func (p *Provider) Formats() []string {
+	return p.FormatList
+}
+
+func (p *Provider) ParamLimits() tts.ParamLimits {
+	return p.Limits
+}
+
+func (p *Provider) Synthesize(ctx context.Context, apiKey string, text string, voiceId int64, format string, params tts.SpeechParams) (*tts.Speech, error) {
+	p.record("Synthesize", apiKey)
+	if p.SynthesizeFunc == nil {
+		return &tts.Speech{VoiceId: voiceId, Format: format}, nil
+	}
+	return p.SynthesizeFunc(ctx, apiKey, text, voiceId, format, params)
+}
+
+var _ tts.Provider = (*Provider)(nil)
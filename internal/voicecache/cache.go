@@ -0,0 +1,186 @@
+package voicecache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/tts"
+	"golang.org/x/sync/singleflight"
+)
+
+// FETCH_TIMEOUT bounds a provider call shared by concurrent fetches, which
+// doesn't stop when one of the callers gives up.
+const FETCH_TIMEOUT = 30 * time.Second
+
+type catalog struct {
+	voices    []*tts.Voice
+	fetchedAt time.Time
+}
+
+type indexedVoice struct {
+	voice     *tts.Voice
+	fetchedAt time.Time
+}
+
+// Cache keeps voice catalogs available to each user and a global index of
+// all voices seen by id. Catalogs and voices older than ttl are fetched
+// again, and concurrent fetches with the same api key share a single provider call.
+// Stale entries are kept until they are replaced, to be used when the
+// provider is down.
+type Cache struct {
+	provider tts.Provider
+	ttl      time.Duration
+
+	mu     sync.RWMutex
+	users  map[uint]*catalog
+	voices map[int64]*indexedVoice
+
+	group singleflight.Group
+
+	now func() time.Time
+	// onWait is called once a caller waits for a fetch, tests use it to
+	// know all callers share it
+	onWait func()
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func New(provider tts.Provider, ttl time.Duration) *Cache {
+	return &Cache{
+		provider: provider,
+		ttl:      ttl,
+		users:    map[uint]*catalog{},
+		voices:   map[int64]*indexedVoice{},
+		now:      time.Now,
+	}
+}
+
+// UserVoices returns voices sorted by id. A stale catalog is returned if
+// it can't be refreshed.
+func (c *Cache) UserVoices(ctx context.Context, userId uint, apiKey string) ([]*tts.Voice, error) {
+	c.mu.RLock()
+	cached, ok := c.users[userId]
+	c.mu.RUnlock()
+
+	if ok && c.fresh(cached.fetchedAt) {
+		c.hits.Add(1)
+		return cached.voices, nil
+	}
+	c.misses.Add(1)
+
+	voices, err := c.Refresh(ctx, userId, apiKey)
+	if err != nil && ok {
+		return cached.voices, nil
+	}
+
+	return voices, err
+}
+
+// Refresh fetches the user's catalog regardless of its age. The fetch goes on
+// for other callers if ctx is done.
+func (c *Cache) Refresh(ctx context.Context, userId uint, apiKey string) ([]*tts.Voice, error) {
+	fetch := c.group.DoChan(fetchKey(apiKey), func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), FETCH_TIMEOUT)
+		defer cancel()
+
+		voices, err := c.provider.Voices(ctx, apiKey)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Slice(voices, func(i, j int) bool { return voices[i].Id < voices[j].Id })
+		return voices, nil
+	})
+
+	if c.onWait != nil {
+		c.onWait()
+	}
+
+	var result singleflight.Result
+	select {
+	case result = <-fetch:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	voices := result.Val.([]*tts.Voice)
+	c.store(userId, voices)
+
+	return voices, nil
+}
+
+// Voice looks up a voice among all catalogs fetched, fetching the user's
+// catalog if the voice isn't there or is stale. A stale voice is returned if
+// it can't be refreshed.
+func (c *Cache) Voice(ctx context.Context, userId uint, apiKey string, voiceId int64) (*tts.Voice, bool) {
+	cached, ok := c.voice(voiceId)
+	if ok && c.fresh(cached.fetchedAt) {
+		return cached.voice, true
+	}
+
+	_, err := c.Refresh(ctx, userId, apiKey)
+	if err != nil && ok {
+		return cached.voice, true
+	}
+	if err != nil {
+		return nil, false
+	}
+
+	cached, ok = c.voice(voiceId)
+	if !ok {
+		return nil, false
+	}
+	return cached.voice, true
+}
+
+func (c *Cache) voice(voiceId int64) (*indexedVoice, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, ok := c.voices[voiceId]
+	return cached, ok
+}
+
+func (c *Cache) fresh(fetchedAt time.Time) bool {
+	return c.now().Sub(fetchedAt) < c.ttl
+}
+
+// Stats returns hits and misses of user catalogs since start.
+func (c *Cache) Stats() (int64, int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Forget drops the user's catalog, e.g. when the api key changes.
+func (c *Cache) Forget(userId uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.users, userId)
+}
+
+func (c *Cache) store(userId uint, voices []*tts.Voice) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.users[userId] = &catalog{voices: voices, fetchedAt: now}
+
+	for _, voice := range voices {
+		c.voices[voice.Id] = &indexedVoice{voice: voice, fetchedAt: now}
+	}
+}
+
+// fetchKey keeps api keys out of the singleflight group.
+func fetchKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,216 @@
+package voicecache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Quiexx/narrator-bot/internal/tts"
+)
+
+type fakeProvider struct {
+	tts.Provider
+
+	calls   atomic.Int64
+	release chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{}
+}
+
+func (p *fakeProvider) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *fakeProvider) Voices(ctx context.Context, apiKey string) ([]*tts.Voice, error) {
+	p.calls.Add(1)
+	if p.release != nil {
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []*tts.Voice{{Id: 2}, {Id: 1}}, nil
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestCache(provider tts.Provider) (*Cache, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := New(provider, time.Hour)
+	cache.now = clock.Now
+	return cache, clock
+}
+
+func TestUserVoicesExpire(t *testing.T) {
+	provider := newFakeProvider()
+	cache, clock := newTestCache(provider)
+	ctx := context.Background()
+
+	voices, err := cache.UserVoices(ctx, 1, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voices) != 2 || voices[0].Id != 1 {
+		t.Fatalf("voices aren't sorted by id: %v", voices)
+	}
+
+	clock.Advance(time.Hour - time.Second)
+	cache.UserVoices(ctx, 1, "key")
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Fatalf("fresh catalog fetched again, %v calls", calls)
+	}
+
+	clock.Advance(time.Second)
+	cache.UserVoices(ctx, 1, "key")
+	if calls := provider.calls.Load(); calls != 2 {
+		t.Fatalf("expired catalog isn't fetched, %v calls", calls)
+	}
+
+	hits, misses := cache.Stats()
+	if hits != 1 || misses != 2 {
+		t.Fatalf("got %v hits and %v misses", hits, misses)
+	}
+}
+
+func TestUserVoicesShareFetch(t *testing.T) {
+	const CALLERS = 10
+
+	provider := newFakeProvider()
+	provider.release = make(chan struct{})
+	cache, _ := newTestCache(provider)
+
+	var waiting sync.WaitGroup
+	waiting.Add(CALLERS)
+	cache.onWait = waiting.Done
+
+	// the first caller gives up, the others still get the catalog
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	errs := make([]error, CALLERS)
+	for i := 0; i < CALLERS; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			callCtx := context.Background()
+			if i == 0 {
+				callCtx = ctx
+			}
+			_, errs[i] = cache.UserVoices(callCtx, uint(i), "key")
+		}(i)
+	}
+
+	waiting.Wait()
+	cancel()
+	close(provider.release)
+	wg.Wait()
+
+	if !errors.Is(errs[0], context.Canceled) {
+		t.Fatalf("caller giving up got %v", errs[0])
+	}
+	for _, err := range errs[1:] {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Fatalf("concurrent fetches weren't shared, %v calls", calls)
+	}
+}
+
+func TestUserVoicesStaleOnFailure(t *testing.T) {
+	provider := newFakeProvider()
+	cache, clock := newTestCache(provider)
+	ctx := context.Background()
+
+	cache.UserVoices(ctx, 1, "key")
+	clock.Advance(2 * time.Hour)
+
+	provider.fail(tts.ErrUnavailable)
+
+	voices, err := cache.UserVoices(ctx, 1, "key")
+	if err != nil {
+		t.Fatalf("stale catalog isn't returned: %v", err)
+	}
+	if len(voices) != 2 {
+		t.Fatalf("got %v voices", len(voices))
+	}
+
+	_, ok := cache.Voice(ctx, 1, "key", 2)
+	if !ok {
+		t.Fatal("stale voice isn't returned")
+	}
+
+	_, err = cache.UserVoices(ctx, 2, "other")
+	if !errors.Is(err, tts.ErrUnavailable) {
+		t.Fatalf("got %v without a stale catalog", err)
+	}
+}
+
+func TestVoiceFetchesOnMiss(t *testing.T) {
+	provider := newFakeProvider()
+	cache, _ := newTestCache(provider)
+	ctx := context.Background()
+
+	voice, ok := cache.Voice(ctx, 1, "key", 1)
+	if !ok || voice.Id != 1 {
+		t.Fatal("voice isn't fetched")
+	}
+
+	_, ok = cache.Voice(ctx, 1, "key", 3)
+	if ok {
+		t.Fatal("unknown voice is found")
+	}
+	if calls := provider.calls.Load(); calls != 2 {
+		t.Fatalf("got %v calls", calls)
+	}
+}
+
+func TestVoiceExpires(t *testing.T) {
+	provider := newFakeProvider()
+	cache, clock := newTestCache(provider)
+	ctx := context.Background()
+
+	cache.Voice(ctx, 1, "key", 1)
+	cache.Voice(ctx, 2, "other", 1)
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Fatalf("fresh voice fetched again, %v calls", calls)
+	}
+
+	clock.Advance(time.Hour)
+	cache.Voice(ctx, 2, "other", 1)
+	if calls := provider.calls.Load(); calls != 2 {
+		t.Fatalf("expired voice isn't fetched, %v calls", calls)
+	}
+}
@@ -0,0 +1,162 @@
+package workerpool
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull = errors.New("queue is full")
+	ErrClosed    = errors.New("pool is closed")
+)
+
+type job struct {
+	run        func()
+	enqueuedAt time.Time
+}
+
+// Stats describes jobs handled since start. Wait is the time jobs spent in
+// the queue before a worker took them.
+type Stats struct {
+	Queued    int
+	Processed int64
+	Rejected  int64
+	WaitTotal time.Duration
+	WaitMax   time.Duration
+}
+
+// Pool runs jobs on a fixed number of workers. Jobs with the same key run one
+// at a time in the order they were submitted, jobs with different keys run
+// concurrently.
+type Pool struct {
+	maxQueue    int
+	observeWait func(time.Duration)
+
+	mu      sync.Mutex
+	queues  map[string][]job
+	queued  int
+	closed  bool
+	stats   Stats
+	pending sync.WaitGroup
+
+	// keys having jobs that no worker runs at the moment
+	ready chan string
+}
+
+// New starts workers that take jobs from a queue of at most maxQueue jobs.
+// observeWait, if not nil, gets the time each job spent in the queue.
+func New(workers int, maxQueue int, observeWait func(time.Duration)) *Pool {
+	p := &Pool{
+		maxQueue:    maxQueue,
+		observeWait: observeWait,
+		queues:      map[string][]job{},
+		ready:       make(chan string, maxQueue),
+	}
+
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+// Submit queues run after the jobs submitted with the same key. It returns
+// ErrQueueFull if maxQueue jobs are already waiting.
+func (p *Pool) Submit(key string, run func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	if p.queued >= p.maxQueue {
+		p.stats.Rejected++
+		return ErrQueueFull
+	}
+
+	queue, active := p.queues[key]
+	p.queues[key] = append(queue, job{run: run, enqueuedAt: time.Now()})
+	p.queued++
+	p.pending.Add(1)
+
+	// a key is in ready or run by a worker while it has a queue
+	if !active {
+		p.ready <- key
+	}
+
+	return nil
+}
+
+// Close rejects new jobs and waits until the queued ones are done.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.pending.Wait()
+	close(p.ready)
+}
+
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Queued = p.queued
+	return stats
+}
+
+func (p *Pool) work() {
+	for key := range p.ready {
+		next, ok := p.take(key)
+		if !ok {
+			continue
+		}
+
+		next.run()
+		p.done(key)
+	}
+}
+
+func (p *Pool) take(key string) (job, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	queue := p.queues[key]
+	if len(queue) == 0 {
+		return job{}, false
+	}
+
+	next := queue[0]
+	queue[0] = job{}
+	p.queues[key] = queue[1:]
+	p.queued--
+
+	wait := time.Since(next.enqueuedAt)
+	p.stats.WaitTotal += wait
+	if wait > p.stats.WaitMax {
+		p.stats.WaitMax = wait
+	}
+	if p.observeWait != nil {
+		p.observeWait(wait)
+	}
+
+	return next, true
+}
+
+func (p *Pool) done(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Processed++
+	p.pending.Done()
+
+	if len(p.queues[key]) == 0 {
+		delete(p.queues, key)
+		return
+	}
+
+	p.ready <- key
+}
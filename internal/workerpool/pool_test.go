@@ -0,0 +1,161 @@
+package workerpool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSameKeyRunsInOrder(t *testing.T) {
+	p := New(4, 1000, nil)
+
+	var mu sync.Mutex
+	order := map[string][]int{}
+	running := map[string]*atomic.Int64{"a": {}, "b": {}}
+	var overlapped atomic.Bool
+
+	for i := 0; i < 100; i++ {
+		for _, key := range []string{"a", "b"} {
+			i, key := i, key
+			err := p.Submit(key, func() {
+				if running[key].Add(1) > 1 {
+					overlapped.Store(true)
+				}
+				defer running[key].Add(-1)
+
+				mu.Lock()
+				order[key] = append(order[key], i)
+				mu.Unlock()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	p.Close()
+
+	if overlapped.Load() {
+		t.Fatal("jobs with the same key ran concurrently")
+	}
+	for key, indexes := range order {
+		for i, index := range indexes {
+			if index != i {
+				t.Fatalf("jobs of %v ran in order %v", key, indexes)
+			}
+		}
+	}
+}
+
+func TestDifferentKeysRunConcurrently(t *testing.T) {
+	p := New(2, 10, nil)
+	defer p.Close()
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+
+	p.Submit("a", func() {
+		close(started)
+		<-done
+	})
+	<-started
+	p.Submit("b", func() { close(done) })
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job of another key waits for a running job")
+	}
+}
+
+func TestSubmitRejectsWhenFull(t *testing.T) {
+	p := New(1, 2, nil)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	p.Submit("a", func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	// the running job doesn't take a place in the queue
+	for i := 0; i < 2; i++ {
+		if err := p.Submit(fmt.Sprint(i), func() {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := p.Submit("c", func() {})
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, want %v", err, ErrQueueFull)
+	}
+
+	stats := p.Stats()
+	if stats.Queued != 2 || stats.Rejected != 1 {
+		t.Fatalf("got %+v", stats)
+	}
+
+	close(release)
+	p.Close()
+}
+
+func TestCloseWaitsForJobs(t *testing.T) {
+	var waits atomic.Int64
+	p := New(1, 10, func(wait time.Duration) {
+		if wait >= 0 {
+			waits.Add(1)
+		}
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var ran atomic.Int64
+
+	p.Submit("a", func() {
+		close(started)
+		<-release
+		ran.Add(1)
+	})
+	<-started
+	p.Submit("b", func() { ran.Add(1) })
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+
+	// Close rejects new jobs before it waits, the ones accepted before run
+	jobs := int64(2)
+	for {
+		err := p.Submit("c", func() { ran.Add(1) })
+		if errors.Is(err, ErrClosed) {
+			break
+		}
+		if err == nil {
+			jobs++
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a job runs")
+	default:
+	}
+
+	close(release)
+	<-closed
+
+	if ran.Load() != jobs {
+		t.Fatalf("Close returned after %v of %v jobs", ran.Load(), jobs)
+	}
+
+	stats := p.Stats()
+	if stats.Processed != jobs || stats.Queued != 0 || waits.Load() != jobs {
+		t.Fatalf("got %+v and %v observed waits", stats, waits.Load())
+	}
+}